@@ -18,6 +18,20 @@ func newJkv(capacity uint32, maxPriority int, onEvicted func(key string, value [
 	return kv, nil
 }
 
+func newJkvWithPolicy(capacity uint32, maxPriority int, policy jlru.Policy, onEvicted func(key string, value []byte) bool) (*jkv, error) {
+	kv := &jkv{}
+	lru, err := jlru.NewPriorityLRUWithOptions[string, []byte](int(capacity), byte(maxPriority), jlru.Options[string, []byte]{
+		HashFunc:  jlru.HashXXHASH,
+		OnEvicted: onEvicted,
+		Policy:    policy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	kv.lru = lru
+	return kv, nil
+}
+
 func (kv *jkv) Get(key string) ([]byte, bool) {
 	data, ok, err := kv.lru.Get(key)
 	if err != nil {
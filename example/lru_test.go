@@ -2,6 +2,7 @@ package example
 
 import (
 	"fmt"
+	jlru "github.com/junjiefly/jlru/lru"
 	"testing"
 )
 
@@ -276,3 +277,62 @@ func BenchmarkParallelJLruRemoveOperation(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkJLruClockGetOperation(b *testing.B) {
+	lru, err := newJkvWithPolicy(uint32(b.N), 100, jlru.PolicyClock, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var vv = []byte("1234")
+	var keys = make([]string, b.N)
+	for i := 0; i < b.N; i++ {
+		keys[i] = fmt.Sprintf("key1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyz_%d", i)
+	}
+	for i := 0; i < b.N; i++ {
+		lru.SetPriority(keys[i], vv, 0)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = lru.Get(keys[i])
+	}
+}
+
+func BenchmarkJLruClockAddOperationWithEvict(b *testing.B) {
+	lru, err := newJkvWithPolicy(10, 100, jlru.PolicyClock, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var vv = []byte("1234")
+	var keys = make([]string, b.N)
+	for i := 0; i < b.N; i++ {
+		keys[i] = fmt.Sprintf("key1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyz_%d", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lru.SetPriority(keys[i], vv, 0)
+		_, _ = lru.Get(keys[i/2])
+	}
+}
+
+func BenchmarkParallelJLruClockGetOperation(b *testing.B) {
+	lru, err := newJkvWithPolicy(uint32(b.N), 100, jlru.PolicyClock, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var vv = []byte("1234")
+	var keys = make([]string, b.N)
+	for i := 0; i < b.N; i++ {
+		keys[i] = fmt.Sprintf("key1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyzkey1234567890abcdefghijklmnopqrstuvwxyz_%d", i)
+	}
+	for i := 0; i < b.N; i++ {
+		lru.SetPriority(keys[i], vv, 0)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = lru.Get(keys[i%b.N])
+			i++
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// Package hashbuilder combines the fields of a composite key into one well
+// mixed hash without allocating:
+//
+//	h := hashbuilder.New().Uint32(k.TenantID).String(k.Path).Int64(k.Version).Sum32()
+//
+// StructHasher builds such a hasher for a struct type by reflection once.
+package hashbuilder
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	seed   = 0x9e3779b97f4a7c15
+	prime1 = 0x87c37b91114253d5
+	prime2 = 0x4cf5ad432745937f
+)
+
+// Builder is a value type, every method returns the builder with one more
+// field added, so it stays on the stack.
+type Builder struct {
+	h uint64
+	n uint64
+}
+
+// New returns an empty builder, the zero Builder may be used as well.
+func New() Builder {
+	return Builder{}
+}
+
+func (b Builder) add(v uint64) Builder {
+	v *= prime1
+	v = bits.RotateLeft64(v, 31)
+	v *= prime2
+	b.h ^= v
+	b.h = bits.RotateLeft64(b.h, 27)*5 + 0x52dce729
+	b.n++
+	return b
+}
+
+func (b Builder) Uint64(x uint64) Builder {
+	return b.add(x)
+}
+
+func (b Builder) Uint32(x uint32) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Uint16(x uint16) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Uint8(x uint8) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Int64(x int64) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Int32(x int32) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Int(x int) Builder {
+	return b.add(uint64(x))
+}
+
+func (b Builder) Bool(x bool) Builder {
+	if x {
+		return b.add(1)
+	}
+	return b.add(0)
+}
+
+// Float64 adds x, 0 and -0 give the same hash since they are equal.
+func (b Builder) Float64(x float64) Builder {
+	if x == 0 {
+		x = 0
+	}
+	return b.add(math.Float64bits(x))
+}
+
+// String adds s together with its length, so "ab","c" and "a","bc" differ.
+func (b Builder) String(s string) Builder {
+	return b.add(xxhash.Sum64String(s)).add(uint64(len(s)))
+}
+
+// Bytes adds p like String(string(p)).
+func (b Builder) Bytes(p []byte) Builder {
+	return b.add(xxhash.Sum64(p)).add(uint64(len(p)))
+}
+
+// Sum64 returns the hash of the fields added so far.
+func (b Builder) Sum64() uint64 {
+	h := b.h ^ b.n ^ seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Sum32 returns the lower 32 bits of Sum64.
+func (b Builder) Sum32() uint32 {
+	return uint32(b.Sum64())
+}
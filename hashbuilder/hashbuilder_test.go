@@ -0,0 +1,93 @@
+package hashbuilder
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type objectKey struct {
+	TenantID uint32
+	Path     string
+	Version  int64
+}
+
+type nestedKey struct {
+	Object objectKey
+	Shards [2]int16
+	Ratio  float64
+	_      uint32
+	Hot    bool
+}
+
+func TestBuilder(t *testing.T) {
+	t.Run("stable", func(t *testing.T) {
+		// 相同字段得到相同哈希，零值Builder与New等价
+		h1 := New().Uint32(7).String("a/b").Int64(3).Sum64()
+		h2 := Builder{}.Uint32(7).String("a/b").Int64(3).Sum64()
+		assert.Equal(t, h1, h2)
+		assert.Equal(t, uint32(h1), New().Uint32(7).String("a/b").Int64(3).Sum32())
+		assert.Equal(t, New().String("ab").Sum64(), New().Bytes([]byte("ab")).Sum64())
+	})
+
+	t.Run("order_and_boundary", func(t *testing.T) {
+		// 字段顺序和字符串边界影响哈希
+		assert.NotEqual(t, New().Uint32(1).Uint32(2).Sum64(), New().Uint32(2).Uint32(1).Sum64())
+		assert.NotEqual(t, New().String("ab").String("c").Sum64(), New().String("a").String("bc").Sum64())
+		assert.NotEqual(t, New().Uint32(0).Sum64(), New().Uint32(0).Uint32(0).Sum64())
+		assert.Equal(t, New().Float64(0).Sum64(), New().Float64(math.Copysign(0, -1)).Sum64())
+	})
+
+	t.Run("no_alloc", func(t *testing.T) {
+		path := "tenant/object"
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = New().Uint32(1).String(path).Int64(2).Sum32()
+		})
+		assert.Equal(t, float64(0), allocs)
+	})
+}
+
+func TestStructHasher(t *testing.T) {
+	t.Run("fields", func(t *testing.T) {
+		// 与手写的Builder链结果一致
+		hash, err := StructHasher[objectKey]()
+		assert.NoError(t, err)
+		k := objectKey{TenantID: 1, Path: "a/b", Version: -3}
+		assert.Equal(t, New().Uint32(1).String("a/b").Int64(-3).Sum64(), hash(k))
+		assert.NotEqual(t, hash(k), hash(objectKey{TenantID: 1, Path: "a/c", Version: -3}))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		// 嵌套结构体和数组展开，忽略空白字段
+		hash, err := StructHasher[nestedKey]()
+		assert.NoError(t, err)
+		k1 := nestedKey{Object: objectKey{1, "p", 2}, Shards: [2]int16{-1, 4}, Ratio: 0, Hot: true}
+		k2 := k1
+		k2.Ratio = math.Copysign(0, -1)
+		assert.Equal(t, k1, k2)
+		assert.Equal(t, hash(k1), hash(k2))
+		k2.Shards[1] = 5
+		assert.NotEqual(t, hash(k1), hash(k2))
+		_, ok := plans.Load(reflect.TypeOf(nestedKey{}))
+		assert.True(t, ok)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := StructHasher[struct{ P *int }]()
+		assert.Equal(t, ErrUnsupportedType, err)
+		_, err = StructHasher[struct{ S []byte }]()
+		assert.Equal(t, ErrUnsupportedType, err)
+		_, err = StructHasher[string]()
+		assert.Equal(t, ErrUnsupportedType, err)
+	})
+
+	t.Run("no_alloc", func(t *testing.T) {
+		hash, _ := StructHasher[objectKey]()
+		k := objectKey{TenantID: 1, Path: "a/b", Version: 3}
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = hash(k)
+		})
+		assert.Equal(t, float64(0), allocs)
+	})
+}
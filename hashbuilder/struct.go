@@ -0,0 +1,120 @@
+package hashbuilder
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// ErrUnsupportedType is returned by StructHasher for types holding pointers,
+// interfaces, slices, maps, channels or functions.
+var ErrUnsupportedType = errors.New("UnsupportedType")
+
+// field is a leaf field of a struct, nested structs and arrays are flattened.
+type field struct {
+	offset uintptr
+	kind   reflect.Kind
+	size   uintptr
+}
+
+var plans sync.Map // reflect.Type -> []field
+
+// StructHasher returns a hasher for the struct type K, which hashes all the
+// fields of K in order with a Builder. The fields are looked up by reflection
+// once per type, hashing a key does not use reflection.
+func StructHasher[K any]() (func(K) uint64, error) {
+	t := reflect.TypeOf((*K)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedType
+	}
+	fields, err := plan(t)
+	if err != nil {
+		return nil, err
+	}
+	return func(k K) uint64 {
+		return hashFields(unsafe.Pointer(&k), fields)
+	}, nil
+}
+
+func plan(t reflect.Type) ([]field, error) {
+	if fields, ok := plans.Load(t); ok {
+		return fields.([]field), nil
+	}
+	fields, err := flatten(t, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	plans.Store(t, fields)
+	return fields, nil
+}
+
+func flatten(t reflect.Type, offset uintptr, fields []field) ([]field, error) {
+	var err error
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return append(fields, field{offset: offset, kind: t.Kind(), size: t.Size()}), nil
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			fields, err = flatten(t.Elem(), offset+uintptr(i)*t.Elem().Size(), fields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" {
+				continue // == ignores blank fields
+			}
+			fields, err = flatten(f.Type, offset+f.Offset, fields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func hashFields(p unsafe.Pointer, fields []field) uint64 {
+	b := New()
+	for _, f := range fields {
+		ptr := unsafe.Add(p, f.offset)
+		switch f.kind {
+		case reflect.String:
+			b = b.String(*(*string)(ptr))
+		case reflect.Float32:
+			b = b.Float64(float64(*(*float32)(ptr)))
+		case reflect.Float64:
+			b = b.Float64(*(*float64)(ptr))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// sign extended like Builder.Int32 and Builder.Int64
+			switch f.size {
+			case 1:
+				b = b.Int64(int64(*(*int8)(ptr)))
+			case 2:
+				b = b.Int64(int64(*(*int16)(ptr)))
+			case 4:
+				b = b.Int64(int64(*(*int32)(ptr)))
+			default:
+				b = b.Int64(*(*int64)(ptr))
+			}
+		default:
+			switch f.size {
+			case 1:
+				b = b.Uint8(*(*uint8)(ptr))
+			case 2:
+				b = b.Uint16(*(*uint16)(ptr))
+			case 4:
+				b = b.Uint32(*(*uint32)(ptr))
+			default:
+				b = b.Uint64(*(*uint64)(ptr))
+			}
+		}
+	}
+	return b.Sum64()
+}
@@ -3,17 +3,18 @@ package list
 import (
 	"errors"
 	"math"
+	"unsafe"
 )
 
 const invalidPos = math.MaxUint32
 
-type Entry[K comparable, V any] struct {
+type Entry[K any, V any] struct {
 	Flag     byte   //类型标记位,非0时表示是一个被标记的节点[flag != 0 means this is not a user node]
 	Priority byte   //优先级，地优先级意味着更容易被驱逐[priority for the entry，low priority means easier to be evicted]
 	prev     uint32 //当前节点在LRU双向链表的前一个节点[prev node in the lru double linked list]
 	next     uint32 //当前节点在LRU双向链表的下一个节点[next node in the lru double linked list]
 	idx      uint32 //block序号
-	HashId   uint32 //哈希值
+	HashId   uint64 //哈希值
 	Key      K      //键
 	Value    V      //值
 
@@ -21,8 +22,12 @@ type Entry[K comparable, V any] struct {
 	ConflictNext uint32 //当前节点在冲突双向链表的下一个节点[next node in the conflict double linked list]
 }
 
+// Match reports whether e holds key. It compares the keys with ==, it panics
+// if K is not comparable, like a []byte key.
+//
+// Deprecated: use List.Match, which compares with the func of the list.
 func (e Entry[K, V]) Match(key K) bool {
-	return e.Key == key
+	return any(e.Key) == any(key)
 }
 
 func (e Entry[K, V]) Idx() uint32 {
@@ -33,42 +38,94 @@ func (e Entry[K, V]) Prev() uint32 {
 	return e.prev
 }
 
-type List[K comparable, V any] struct {
-	cap     uint32        //容量
-	data    []Entry[K, V] //包含所有block的切片
-	freeIdx []uint32      //空闲block序号
-	head    uint32        //lru队列头,
-	tail    uint32        //lru队列尾,靠近尾部的节点更容易被驱逐[node close to the tail means easier to be evicted]
-	size    uint32        //当前lru队列长度
+func (e Entry[K, V]) Next() uint32 {
+	return e.next
+}
+
+// chunkShift is the log2 of the entries in a chunk of the arena, the chunks of
+// small lists are shorter.
+const chunkShift = 12
+
+type List[K any, V any] struct {
+	cap     uint32            //容量
+	chunks  [][]Entry[K, V]   //按需分配的block分块,idx>>shift为分块序号[chunks of blocks allocated on demand, addressed by idx>>shift]
+	shift   uint32            //分块大小的log2[log2 of the chunk size]
+	mask    uint32            //块内序号的掩码[mask of the block idx inside its chunk]
+	next    uint32            //从未使用过的最小block序号[lowest block idx never handed out]
+	freeIdx []uint32          //被释放的block序号[blocks released after use]
+	head    uint32            //lru队列头,
+	tail    uint32            //lru队列尾,靠近尾部的节点更容易被驱逐[node close to the tail means easier to be evicted]
+	size    uint32            //当前lru队列长度
+	equal   func(a, b K) bool //键比较函数[compares the keys for Match and Find]
 }
 
 func NewList[K comparable, V any](capacity int) *List[K, V] {
-	ll := &List[K, V]{
-		freeIdx: make([]uint32, capacity),
-		head:    invalidPos,
-		tail:    invalidPos,
+	return NewListFunc[K, V](capacity, equal[K])
+}
 
-		size: 0,
-		cap:  uint32(capacity),
+// NewListFunc returns a list whose keys are compared by equal, for the keys
+// which are not comparable, like []byte.
+func NewListFunc[K any, V any](capacity int, equal func(a, b K) bool) *List[K, V] {
+	shift := uint32(0)
+	for shift < chunkShift && 1<<shift < capacity {
+		shift++
 	}
-	ll.data = make([]Entry[K, V], capacity)
-	for i := 0; i < capacity; i++ {
-		ll.freeIdx[i] = uint32(i)
+	ll := &List[K, V]{
+		shift: shift,
+		mask:  1<<shift - 1,
+		head:  invalidPos,
+		tail:  invalidPos,
+
+		size:  0,
+		cap:   uint32(capacity),
+		equal: equal,
 	}
 	return ll
 }
 
-func (l *List[K, V]) getNodeIdx() (uint32, bool) {
-	if len(l.freeIdx) == 0 {
-		return invalidPos, false
-	}
-	idx := l.freeIdx[len(l.freeIdx)-1]       // get last
-	l.freeIdx = l.freeIdx[:len(l.freeIdx)-1] // eject last
-	l.data[idx].ConflictPrev = invalidPos
-	l.data[idx].ConflictNext = invalidPos
-	l.data[idx].prev = invalidPos
-	l.data[idx].next = invalidPos
-	return idx, true
+// node returns the block idx, its chunk must have been allocated. Every
+// operation resolves the nodes it links once and works on the pointers.
+func (l *List[K, V]) node(idx uint32) *Entry[K, V] {
+	return &l.chunks[idx>>l.shift][idx&l.mask]
+}
+
+// MemoryUsage returns the bytes allocated for the entries and for the free
+// index stack, the memory referenced by keys and values is not counted.
+func (l *List[K, V]) MemoryUsage() (arena uint64, freeIdx uint64) {
+	arena = uint64(len(l.chunks)) << l.shift * uint64(unsafe.Sizeof(Entry[K, V]{}))
+	freeIdx = uint64(cap(l.freeIdx)) * uint64(unsafe.Sizeof(uint32(0)))
+	return arena, freeIdx
+}
+
+// Allocated returns the number of blocks in the allocated chunks, every idx
+// handed out so far is below it. The callers keeping a value per block grow
+// with it.
+func (l *List[K, V]) Allocated() uint32 {
+	return uint32(len(l.chunks)) << l.shift
+}
+
+// getNode hands out a block from the bump pointer, then from the released ones.
+func (l *List[K, V]) getNode() (*Entry[K, V], bool) {
+	var idx uint32
+	if l.next < l.cap {
+		idx = l.next
+		if int(idx>>l.shift) == len(l.chunks) {
+			l.chunks = append(l.chunks, make([]Entry[K, V], 1<<l.shift))
+		}
+		l.next++
+	} else if len(l.freeIdx) > 0 {
+		idx = l.freeIdx[len(l.freeIdx)-1]        // get last
+		l.freeIdx = l.freeIdx[:len(l.freeIdx)-1] // eject last
+	} else {
+		return nil, false
+	}
+	e := l.node(idx)
+	e.ConflictPrev = invalidPos
+	e.ConflictNext = invalidPos
+	e.prev = invalidPos
+	e.next = invalidPos
+	e.idx = idx
+	return e, true
 }
 
 func (l *List[K, V]) putNodeIdx(idx uint32) {
@@ -91,7 +148,7 @@ func (l *List[K, V]) Front() *Entry[K, V] {
 		return nil
 	}
 	if l.head != invalidPos {
-		return &l.data[l.head]
+		return l.node(l.head)
 	}
 	return nil
 }
@@ -102,7 +159,7 @@ func (l *List[K, V]) Back() *Entry[K, V] {
 		return nil
 	}
 	if l.tail != invalidPos {
-		return &l.data[l.tail]
+		return l.node(l.tail)
 	}
 	return nil
 }
@@ -115,31 +172,32 @@ func (l *List[K, V]) remove(e *Entry[K, V]) (V, error) {
 	if e.next == invalidPos || e.prev == invalidPos {
 		return e.Value, errors.New("unknown node")
 	}
-	if l.cap <= e.idx || e.idx < 0 {
+	if l.next <= e.idx || e.idx < 0 {
 		return e.Value, errors.New("invalid node")
 	}
-	node := l.data[e.idx]
-	if node.prev == invalidPos || node.next == invalidPos {
+	node := l.node(e.idx)
+	prev, next := node.prev, node.next
+	if prev == invalidPos || next == invalidPos {
 		return e.Value, errors.New("invalid node")
 	}
-	if e.next != node.next || e.prev != node.prev {
+	if e.next != next || e.prev != prev {
 		return e.Value, errors.New("list changed")
 	}
 	value := e.Value
-	l.data[node.prev].next = node.next
-	l.data[node.next].prev = node.prev
+	l.node(prev).next = next
+	l.node(next).prev = prev
 
 	if l.head == e.idx {
-		l.head = node.next
+		l.head = next
 	}
 	if l.tail == e.idx {
-		l.tail = node.prev
+		l.tail = prev
 	}
-	l.data[e.idx].prev = invalidPos
-	l.data[e.idx].next = invalidPos
-	l.data[e.idx].ConflictPrev = invalidPos
-	l.data[e.idx].ConflictNext = invalidPos
-	l.putNodeIdx(e.idx)
+	node.prev = invalidPos
+	node.next = invalidPos
+	node.ConflictPrev = invalidPos
+	node.ConflictNext = invalidPos
+	l.putNodeIdx(node.idx)
 	l.size--
 	if l.size == 0 {
 		l.head = invalidPos
@@ -153,55 +211,53 @@ func (l *List[K, V]) Remove(e *Entry[K, V]) (V, error) {
 }
 
 func (l *List[K, V]) PushFront(key K, value V, priority byte) (*Entry[K, V], error) {
-	idx, ok := l.getNodeIdx()
+	e, ok := l.getNode()
 	if !ok {
 		return nil, errors.New("memory pool exhausted")
 	}
-	l.data[idx].Priority = priority
-	l.data[idx].Key = key
-	l.data[idx].Value = value
-	l.data[idx].idx = idx
+	e.Priority = priority
+	e.Key = key
+	e.Value = value
 
 	if l.head != invalidPos && l.tail != invalidPos {
-		l.data[l.head].prev = idx
-		l.data[l.tail].next = idx
-		l.data[idx].next = l.head
-		l.data[idx].prev = l.tail
-		l.head = idx
+		l.node(l.head).prev = e.idx
+		l.node(l.tail).next = e.idx
+		e.next = l.head
+		e.prev = l.tail
+		l.head = e.idx
 	} else {
-		l.data[idx].next = idx
-		l.data[idx].prev = idx
-		l.head = idx
-		l.tail = idx
+		e.next = e.idx
+		e.prev = e.idx
+		l.head = e.idx
+		l.tail = e.idx
 	}
 	l.size++
-	return &l.data[idx], nil
+	return e, nil
 }
 
 func (l *List[K, V]) PushBack(key K, value V, priority byte) (*Entry[K, V], error) {
-	idx, ok := l.getNodeIdx()
+	e, ok := l.getNode()
 	if !ok {
 		return nil, errors.New("memory pool exhausted")
 	}
-	l.data[idx].Priority = priority
-	l.data[idx].Key = key
-	l.data[idx].Value = value
-	l.data[idx].idx = idx
+	e.Priority = priority
+	e.Key = key
+	e.Value = value
 
 	if l.head != invalidPos && l.tail != invalidPos {
-		l.data[l.tail].next = idx
-		l.data[l.head].prev = idx
-		l.data[idx].prev = l.tail
-		l.data[idx].next = l.head
-		l.tail = idx
+		l.node(l.tail).next = e.idx
+		l.node(l.head).prev = e.idx
+		e.prev = l.tail
+		e.next = l.head
+		l.tail = e.idx
 	} else {
-		l.data[idx].next = idx
-		l.data[idx].prev = idx
-		l.head = idx
-		l.tail = idx
+		e.next = e.idx
+		e.prev = e.idx
+		l.head = e.idx
+		l.tail = e.idx
 	}
 	l.size++
-	return &l.data[idx], nil
+	return e, nil
 }
 
 func (l *List[K, V]) InsertBefore(k K, v V, mark *Entry[K, V]) (*Entry[K, V], error) {
@@ -209,36 +265,36 @@ func (l *List[K, V]) InsertBefore(k K, v V, mark *Entry[K, V]) (*Entry[K, V], er
 }
 
 func (l *List[K, V]) insertBefore(k K, v V, mark *Entry[K, V]) (*Entry[K, V], error) {
-	idx, ok := l.getNodeIdx()
+	e, ok := l.getNode()
 	if !ok {
 		return nil, errors.New("memory pool exhausted")
 	}
 	if mark == nil {
 		return nil, errors.New("invalid mark node")
 	}
-	if l.cap <= mark.idx || mark.idx < 0 {
+	if l.next <= mark.idx || mark.idx < 0 {
 		return nil, errors.New("invalid mark node")
 	}
-	markNode := l.data[mark.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	markNode := l.node(mark.idx)
+	prev := markNode.prev
+	if prev == invalidPos || markNode.next == invalidPos {
 		return nil, errors.New("invalid node")
 	}
-	l.data[idx].Priority = mark.Priority
-	l.data[idx].Key = k
-	l.data[idx].Value = v
-	l.data[idx].idx = idx
+	e.Priority = mark.Priority
+	e.Key = k
+	e.Value = v
 
-	l.data[idx].next = markNode.idx
-	l.data[idx].prev = markNode.prev
+	e.next = markNode.idx
+	e.prev = prev
 
-	l.data[markNode.prev].next = idx
-	l.data[markNode.idx].prev = idx
+	l.node(prev).next = e.idx
+	markNode.prev = e.idx
 
 	if l.head == markNode.idx || l.head == invalidPos {
-		l.head = idx
+		l.head = e.idx
 	}
 	l.size++
-	return &l.data[idx], nil
+	return e, nil
 }
 
 func (l *List[K, V]) InsertAfter(k K, v V, mark *Entry[K, V]) (*Entry[K, V], error) {
@@ -246,36 +302,36 @@ func (l *List[K, V]) InsertAfter(k K, v V, mark *Entry[K, V]) (*Entry[K, V], err
 }
 
 func (l *List[K, V]) insertAfter(k K, v V, mark *Entry[K, V]) (*Entry[K, V], error) {
-	idx, ok := l.getNodeIdx()
+	e, ok := l.getNode()
 	if !ok {
 		return nil, errors.New("memory pool exhausted")
 	}
 	if mark == nil {
 		return nil, errors.New("invalid mark node")
 	}
-	if l.cap <= mark.idx || mark.idx < 0 {
+	if l.next <= mark.idx || mark.idx < 0 {
 		return nil, errors.New("invalid mark node")
 	}
-	markNode := l.data[mark.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	markNode := l.node(mark.idx)
+	next := markNode.next
+	if markNode.prev == invalidPos || next == invalidPos {
 		return nil, errors.New("invalid node")
 	}
-	l.data[idx].Priority = mark.Priority - 1
-	l.data[idx].Key = k
-	l.data[idx].Value = v
-	l.data[idx].idx = idx
+	e.Priority = mark.Priority - 1
+	e.Key = k
+	e.Value = v
 
-	l.data[idx].prev = markNode.idx
-	l.data[idx].next = markNode.next
+	e.prev = markNode.idx
+	e.next = next
 
-	l.data[markNode.next].prev = idx
-	l.data[markNode.idx].next = idx
+	l.node(next).prev = e.idx
+	markNode.next = e.idx
 
 	if l.tail == markNode.idx || l.tail == invalidPos {
-		l.tail = idx
+		l.tail = e.idx
 	}
 	l.size++
-	return &l.data[idx], nil
+	return e, nil
 }
 
 // MoveToFront moves element e to the front of list l.
@@ -285,11 +341,12 @@ func (l *List[K, V]) MoveToFront(e *Entry[K, V]) error {
 	if e == nil {
 		return errors.New("invalid node")
 	}
-	if l.cap <= e.idx || e.idx < 0 {
+	if l.next <= e.idx || e.idx < 0 {
 		return errors.New("invalid node")
 	}
-	markNode := l.data[e.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	node := l.node(e.idx)
+	prev, next := node.prev, node.next
+	if prev == invalidPos || next == invalidPos {
 		return errors.New("invalid node")
 	}
 	if e.idx == l.head {
@@ -297,16 +354,16 @@ func (l *List[K, V]) MoveToFront(e *Entry[K, V]) error {
 	}
 	if e.idx == l.tail {
 		l.head = e.idx
-		l.tail = e.prev
+		l.tail = prev
 	} else {
-		l.data[e.prev].next = e.next
-		l.data[e.next].prev = e.prev
+		l.node(prev).next = next
+		l.node(next).prev = prev
 
-		l.data[l.tail].next = e.idx
-		l.data[l.head].prev = e.idx
+		l.node(l.tail).next = e.idx
+		l.node(l.head).prev = e.idx
 
-		l.data[e.idx].next = l.head
-		l.data[e.idx].prev = l.tail
+		node.next = l.head
+		node.prev = l.tail
 
 		l.head = e.idx
 	}
@@ -320,28 +377,29 @@ func (l *List[K, V]) MoveToBack(e *Entry[K, V]) error {
 	if e == nil {
 		return errors.New("invalid node")
 	}
-	if l.cap <= e.idx || e.idx < 0 {
+	if l.next <= e.idx || e.idx < 0 {
 		return errors.New("invalid node")
 	}
-	markNode := l.data[e.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	node := l.node(e.idx)
+	prev, next := node.prev, node.next
+	if prev == invalidPos || next == invalidPos {
 		return errors.New("invalid node")
 	}
 	if e.idx == l.tail {
 		return nil
 	}
 	if e.idx == l.head {
-		l.head = e.next
+		l.head = next
 		l.tail = e.idx
 	} else {
-		l.data[e.prev].next = e.next
-		l.data[e.next].prev = e.prev
+		l.node(prev).next = next
+		l.node(next).prev = prev
 
-		l.data[l.tail].next = e.idx
-		l.data[l.head].prev = e.idx
+		l.node(l.tail).next = e.idx
+		l.node(l.head).prev = e.idx
 
-		l.data[e.idx].next = l.head
-		l.data[e.idx].prev = l.tail
+		node.next = l.head
+		node.prev = l.tail
 
 		l.tail = e.idx
 	}
@@ -353,56 +411,55 @@ func (l *List[K, V]) MoveAfter(e *Entry[K, V], mark *Entry[K, V]) error {
 	if e == nil {
 		return errors.New("invalid node")
 	}
-	if l.cap <= e.idx || e.idx < 0 {
+	if l.next <= e.idx || e.idx < 0 {
 		return errors.New("invalid node")
 	}
 	if mark == nil {
 		return errors.New("invalid mark node")
 	}
-	if l.cap <= mark.idx || mark.idx < 0 {
+	if l.next <= mark.idx || mark.idx < 0 {
 		return errors.New("invalid mark node")
 	}
-	node := l.data[e.idx]
-	if node.prev == invalidPos || node.next == invalidPos {
+	node := l.node(e.idx)
+	ePrev, eNext := node.prev, node.next
+	if ePrev == invalidPos || eNext == invalidPos {
 		return errors.New("invalid node")
 	}
-	markNode := l.data[mark.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	markNode := l.node(mark.idx)
+	mNext := markNode.next
+	if markNode.prev == invalidPos || mNext == invalidPos {
 		return errors.New("invalid mark node")
 	}
 	if e.idx == markNode.idx {
 		return nil
 	}
-	if e.prev == markNode.idx {
+	if ePrev == markNode.idx {
 		if e.idx == l.head && l.tail == markNode.idx {
 		} else {
 			return nil
 		}
 	}
 	if e.idx == l.head && mark.idx == l.tail {
-		l.head = e.next
+		l.head = eNext
 		l.tail = e.idx
 	} else {
-		mNext := markNode.next
-		ePrev := node.prev
-		eNext := node.next
 		if e.idx == l.tail {
 			l.tail = ePrev
 		} else if markNode.idx == l.tail {
 			l.tail = e.idx
 		}
 		if e.idx == l.head {
-			l.head = node.next
+			l.head = eNext
 		}
 
-		l.data[e.idx].prev = markNode.idx
-		l.data[e.idx].next = mNext
+		node.prev = markNode.idx
+		node.next = mNext
 
-		l.data[eNext].prev = ePrev
-		l.data[ePrev].next = eNext
+		l.node(eNext).prev = ePrev
+		l.node(ePrev).next = eNext
 
-		l.data[mNext].prev = e.idx
-		l.data[markNode.idx].next = e.idx
+		l.node(mNext).prev = e.idx
+		markNode.next = e.idx
 	}
 	return nil
 }
@@ -412,27 +469,29 @@ func (l *List[K, V]) MoveBefore(e *Entry[K, V], mark *Entry[K, V]) error {
 	if e == nil {
 		return errors.New("invalid node")
 	}
-	if l.cap <= e.idx || e.idx < 0 {
+	if l.next <= e.idx || e.idx < 0 {
 		return errors.New("invalid node")
 	}
 	if mark == nil {
 		return errors.New("invalid mark node")
 	}
-	if l.cap <= mark.idx || mark.idx < 0 {
+	if l.next <= mark.idx || mark.idx < 0 {
 		return errors.New("invalid mark node")
 	}
-	node := l.data[e.idx]
-	if node.prev == invalidPos || node.next == invalidPos {
+	node := l.node(e.idx)
+	ePrev, eNext := node.prev, node.next
+	if ePrev == invalidPos || eNext == invalidPos {
 		return errors.New("invalid node")
 	}
-	markNode := l.data[mark.idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	markNode := l.node(mark.idx)
+	mPrev := markNode.prev
+	if mPrev == invalidPos || markNode.next == invalidPos {
 		return errors.New("invalid mark node")
 	}
 	if e.idx == markNode.idx {
 		return nil
 	}
-	if e.next == markNode.idx {
+	if eNext == markNode.idx {
 		if e.idx == l.tail && l.head == markNode.idx {
 		} else {
 			return nil
@@ -440,11 +499,8 @@ func (l *List[K, V]) MoveBefore(e *Entry[K, V], mark *Entry[K, V]) error {
 	}
 	if e.idx == l.tail && mark.idx == l.head {
 		l.head = e.idx
-		l.tail = e.prev
+		l.tail = ePrev
 	} else {
-		mPrev := markNode.prev
-		ePrev := node.prev
-		eNext := node.next
 		if e.idx == l.tail {
 			l.tail = ePrev
 		}
@@ -453,14 +509,14 @@ func (l *List[K, V]) MoveBefore(e *Entry[K, V], mark *Entry[K, V]) error {
 		} else if markNode.idx == l.head {
 			l.head = e.idx
 		}
-		l.data[e.idx].prev = mPrev
-		l.data[e.idx].next = markNode.idx
+		node.prev = mPrev
+		node.next = markNode.idx
 
-		l.data[ePrev].next = eNext
-		l.data[eNext].prev = ePrev
+		l.node(ePrev).next = eNext
+		l.node(eNext).prev = ePrev
 
-		l.data[mPrev].next = e.idx
-		l.data[markNode.idx].prev = e.idx
+		l.node(mPrev).next = e.idx
+		markNode.prev = e.idx
 	}
 	return nil
 }
@@ -468,12 +524,13 @@ func (l *List[K, V]) MoveBefore(e *Entry[K, V], mark *Entry[K, V]) error {
 func (l *List[K, V]) Iterate() (keys []K, vals []V, prioritys []byte) {
 	current := l.head
 	for current != invalidPos {
-		if l.data[current].Flag == 0 {
-			keys = append(keys, l.data[current].Key)
-			vals = append(vals, l.data[current].Value)
-			prioritys = append(prioritys, l.data[current].Priority)
+		node := l.node(current)
+		if node.Flag == 0 {
+			keys = append(keys, node.Key)
+			vals = append(vals, node.Value)
+			prioritys = append(prioritys, node.Priority)
 		}
-		current = l.data[current].next
+		current = node.next
 		if current == l.head {
 			break
 		}
@@ -482,53 +539,76 @@ func (l *List[K, V]) Iterate() (keys []K, vals []V, prioritys []byte) {
 }
 
 func (l *List[K, V]) Entry(idx uint32) (*Entry[K, V], error) {
-	if l.cap <= idx || idx < 0 || idx == invalidPos {
+	if l.next <= idx || idx < 0 || idx == invalidPos {
 		return nil, errors.New("invalid node")
 	}
-	markNode := l.data[idx]
+	markNode := l.node(idx)
 	if markNode.prev == invalidPos || markNode.next == invalidPos {
 		return nil, errors.New("invalid node")
 	}
-	return &l.data[idx], nil
+	return markNode, nil
 }
 
 func (l *List[K, V]) UpdateEntry(idx uint32, e *Entry[K, V]) error {
-	if l.cap <= idx || idx < 0 || idx == invalidPos {
+	if l.next <= idx || idx < 0 || idx == invalidPos {
 		return errors.New("invalid node")
 	}
-	markNode := l.data[idx]
-	if markNode.prev == invalidPos || markNode.next == invalidPos {
+	node := l.node(idx)
+	if node.prev == invalidPos || node.next == invalidPos {
 		return errors.New("invalid node")
 	}
 	if e == nil {
 		return errors.New("invalid node")
 	}
-	l.data[idx].Priority = e.Priority
-	l.data[idx].Key = e.Key
-	l.data[idx].HashId = e.HashId
-	l.data[idx].Value = e.Value
+	node.Priority = e.Priority
+	node.Key = e.Key
+	node.HashId = e.HashId
+	node.Value = e.Value
 	return nil
 }
 
 func (l *List[K, V]) Clear() {
-	l.data = nil
+	l.chunks = nil
+	l.next = 0
 	l.freeIdx = nil
 	l.head = invalidPos
 	l.tail = invalidPos
 	l.size = 0
 }
 
+// Reset removes all the nodes but keeps the allocated chunks for reuse, the
+// entries are zeroed so they do not keep keys and values alive.
+func (l *List[K, V]) Reset() {
+	var empty Entry[K, V]
+	for _, chunk := range l.chunks {
+		for i := range chunk {
+			chunk[i] = empty
+		}
+	}
+	l.next = 0
+	l.freeIdx = l.freeIdx[:0]
+	l.head = invalidPos
+	l.tail = invalidPos
+	l.size = 0
+}
+
 func equal[K comparable](a, b K) bool {
 	return a == b
 }
 
+// Match reports whether e holds key.
+func (l *List[K, V]) Match(e *Entry[K, V], key K) bool {
+	return l.equal(e.Key, key)
+}
+
 func (l *List[K, V]) Find(k K) (*Entry[K, V], bool) {
 	curr := l.head
 	for curr != invalidPos {
-		if equal(l.data[curr].Key, k) {
-			return &l.data[curr], true
+		node := l.node(curr)
+		if l.equal(node.Key, k) {
+			return node, true
 		}
-		curr = l.data[curr].next
+		curr = node.next
 		if curr == l.head {
 			return nil, false
 		}
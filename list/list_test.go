@@ -1,8 +1,10 @@
 package list
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
+	"unsafe"
 )
 
 func TestNewList(t *testing.T) {
@@ -14,8 +16,97 @@ func TestNewList(t *testing.T) {
 	if list.cap != uint32(capacity) {
 		t.Errorf("Expected data length %d, got %d", capacity, list.cap)
 	}
-	if len(list.freeIdx) != capacity {
-		t.Errorf("Expected freeIdx size %d, got %d", capacity, len(list.freeIdx))
+	if len(list.freeIdx) != 0 || len(list.chunks) != 0 {
+		t.Errorf("Expected no block allocated, got %d chunks", len(list.chunks))
+	}
+}
+
+func TestEntrySize(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("sizes are for 64-bit platforms")
+	}
+	// 64位的HashId占用idx之后的对齐空间，节点大小与32位哈希时相同
+	// 策略的附加状态不放在Entry里，每个节点都要为它付出内存
+	if size := unsafe.Sizeof(Entry[string, []byte]{}); size != 72 {
+		t.Errorf("Expected Entry[string, []byte] of 72 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(Entry[uint64, uint64]{}); size != 48 {
+		t.Errorf("Expected Entry[uint64, uint64] of 48 bytes, got %d", size)
+	}
+}
+
+func TestChunkedArena(t *testing.T) {
+	capacity := 3<<chunkShift + 1
+	list := NewList[int, int](capacity)
+	if list.shift != chunkShift {
+		t.Fatalf("Expected shift %d, got %d", chunkShift, list.shift)
+	}
+	first, _ := list.PushBack(0, 0, 0)
+	// 分块按需分配，已有节点的地址不变
+	for i := 1; i < capacity; i++ {
+		if _, err := list.PushBack(i, i, 0); err != nil {
+			t.Fatal(err)
+		}
+		if want := i>>chunkShift + 1; len(list.chunks) != want {
+			t.Fatalf("Expected %d chunks after %d pushes, got %d", want, i+1, len(list.chunks))
+		}
+		if want := uint32(i>>chunkShift+1) << chunkShift; list.Allocated() != want {
+			t.Fatalf("Expected %d allocated blocks after %d pushes, got %d", want, i+1, list.Allocated())
+		}
+	}
+	if e, _ := list.Entry(0); e != first || e.Key != 0 {
+		t.Error("Expected entry 0 not moved")
+	}
+	if _, err := list.PushBack(capacity, 0, 0); err == nil {
+		t.Error("Expected memory pool exhausted error")
+	}
+	// 释放的block在容量用完后复用
+	list.Remove(first)
+	e, err := list.PushBack(capacity, 0, 0)
+	if err != nil || e.Idx() != 0 {
+		t.Fatalf("Expected block 0 reused, got %v", err)
+	}
+	arena, _ := list.MemoryUsage()
+	if want := uint64(len(list.chunks)) << chunkShift * uint64(unsafe.Sizeof(Entry[int, int]{})); arena != want {
+		t.Errorf("Expected arena %d, got %d", want, arena)
+	}
+}
+
+func TestSmallChunk(t *testing.T) {
+	list := NewList[int, int](5)
+	list.PushBack(1, 1, 0)
+	// 小容量只分配一个不小于容量的分块
+	if len(list.chunks) != 1 || len(list.chunks[0]) != 8 {
+		t.Errorf("Expected one chunk of 8 blocks, got %d", len(list.chunks))
+	}
+	if _, err := list.Entry(1); err == nil {
+		t.Error("Expected invalid node error for an unused block")
+	}
+}
+
+func TestEntryMatch(t *testing.T) {
+	// 已废弃的Entry.Match仍用==比较
+	list := NewList[string, int](4)
+	e, _ := list.PushBack("key1", 1, 0)
+	if !e.Match("key1") || e.Match("key2") {
+		t.Error("Expected Entry.Match to compare with ==")
+	}
+}
+
+func TestNewListFunc(t *testing.T) {
+	// 不可比较的key使用传入的比较函数
+	list := NewListFunc[[]byte, int](4, bytes.Equal)
+	list.PushBack([]byte("key1"), 1, 0)
+	list.PushBack([]byte("key2"), 2, 0)
+	e, ok := list.Find([]byte("key2"))
+	if !ok || e.Value != 2 {
+		t.Fatal("Expected key2 found")
+	}
+	if !list.Match(e, []byte("key2")) || list.Match(e, []byte("key1")) {
+		t.Error("Expected Match to compare by value")
+	}
+	if _, ok := list.Find([]byte("key3")); ok {
+		t.Error("Expected key3 not found")
 	}
 }
 
@@ -210,6 +301,30 @@ func TestClear(t *testing.T) {
 	}
 }
 
+func TestReset(t *testing.T) {
+	list := NewList[string, int](3)
+	e, _ := list.PushFront("A", 1, 0)
+	list.PushFront("B", 2, 0)
+	list.Remove(e)
+	chunk := &list.chunks[0][0]
+	list.Reset()
+	if list.Len() != 0 || list.Front() != nil || len(list.freeIdx) != 0 {
+		t.Fatal("Reset operation failed")
+	}
+	if list.chunks[0][1].Key != "" {
+		t.Error("Expected entries zeroed")
+	}
+	// Reset后复用已分配的分块
+	e, err := list.PushFront("C", 3, 0)
+	if err != nil || e != chunk {
+		t.Errorf("Expected chunk reused, got %v", err)
+	}
+	_, values, _ := list.Iterate()
+	if !reflect.DeepEqual(values, []int{3}) {
+		t.Errorf("Unexpected iteration result after Reset")
+	}
+}
+
 func BenchmarkNewList(b *testing.B) {
 	capacity := 100
 	for i := 0; i < b.N; i++ {
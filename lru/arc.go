@@ -0,0 +1,104 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+// arc keeps t1 (seen once) in the back segment and t2 (seen at least twice) in
+// the front segment of every priority band. b1 and b2 only remember the hashes
+// of the entries evicted from t1 and t2, a hit in them adapts the target size
+// of t1. Which list loses an entry is decided by arc, the priority bands only
+// decide which entry of that list goes first.
+type arcState struct {
+	c     uint32 // capacity of the cache
+	p     uint32 // target size of t1
+	b1    *ghostList
+	b2    *ghostList
+	b2Hit bool // the key being inserted was found in b2
+}
+
+func newArcState(capacity int) *arcState {
+	return &arcState{
+		c:  uint32(capacity),
+		b1: newGhostList(capacity),
+		b2: newGhostList(capacity),
+	}
+}
+
+func (arc *arcState) reset() {
+	arc.p = 0
+	arc.b1.reset()
+	arc.b2.reset()
+	arc.b2Hit = false
+}
+
+// arcAdmit adapts the target size of t1 for a new key and returns the segment
+// the key goes to.
+func (lru *LRU[K, V]) arcAdmit(hashId uint64) byte {
+	arc := lru.arc
+	arc.b2Hit = false
+	b1, b2 := arc.b1.Len(), arc.b2.Len()
+	if _, ok := arc.b1.remove(hashId); ok {
+		delta := uint32(1)
+		if b2 > b1 {
+			delta = b2 / b1
+		}
+		arc.p += delta
+		if arc.p > arc.c {
+			arc.p = arc.c
+		}
+		return segFrequent
+	}
+	if _, ok := arc.b2.remove(hashId); ok {
+		delta := uint32(1)
+		if b1 > b2 {
+			delta = b1 / b2
+		}
+		if arc.p > delta {
+			arc.p -= delta
+		} else {
+			arc.p = 0
+		}
+		arc.b2Hit = true
+		return segFrequent
+	}
+	return segRecent
+}
+
+// arcHit moves an entry of t1 to t2.
+func (lru *LRU[K, V]) arcHit(e *jlist.Entry[K, V]) {
+	if lru.segs[e.Idx()] == segRecent {
+		lru.segDel(e.Priority, segRecent)
+		lru.segAdd(e.Priority, segFrequent)
+		lru.segs[e.Idx()] = segFrequent
+	}
+}
+
+// arcGhost remembers an evicted entry in b1 or b2.
+func (lru *LRU[K, V]) arcGhost(e *jlist.Entry[K, V]) {
+	arc := lru.arc
+	if lru.segs[e.Idx()] == segRecent {
+		arc.b1.push(e.HashId, 0)
+	} else {
+		arc.b2.push(e.HashId, 0)
+	}
+	for arc.b1.Len() > 0 && lru.segLen[segRecent]+arc.b1.Len() > arc.c {
+		arc.b1.popOldest()
+	}
+	for arc.b2.Len() > 0 && lru.segLen[segRecent]+lru.segLen[segFrequent]+arc.b1.Len()+arc.b2.Len() > 2*arc.c {
+		arc.b2.popOldest()
+	}
+}
+
+func (lru *LRU[K, V]) arcOldest() *jlist.Entry[K, V] {
+	arc := lru.arc
+	first, second := segFrequent, segRecent
+	t1 := lru.segLen[segRecent]
+	if t1 > 0 && (t1 > arc.p || (t1 == arc.p && arc.b2Hit)) {
+		first, second = segRecent, segFrequent
+	}
+	if e := lru.segmentOldest(first); e != nil {
+		return e
+	}
+	return lru.segmentOldest(second)
+}
@@ -0,0 +1,130 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func newPolicyLRU(capacity int, maxPriority byte, policy Policy) *LRU[string, []byte] {
+	lru, _ := NewPriorityLRUWithOptions[string, []byte](capacity, maxPriority, Options[string, []byte]{
+		HashFunc: HashXXHASH,
+		Policy:   policy,
+	})
+	return lru
+}
+
+// hitRatio 用trace回放缓存，未命中时写入
+func hitRatio(lru *LRU[string, []byte], trace []string) float64 {
+	var hits int
+	for _, key := range trace {
+		_, ok, _ := lru.Get(key)
+		if ok {
+			hits++
+			continue
+		}
+		_ = lru.Add(key, nil, 0)
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// zipfTrace 热点数据服从zipf分布，每隔scanEvery次访问插入一段只访问一次的扫描
+func zipfTrace(n int, keys uint64, scanEvery int, scanLen int) []string {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, keys-1)
+	trace := make([]string, 0, n)
+	scan := 0
+	for len(trace) < n {
+		trace = append(trace, fmt.Sprintf("hot_%d", zipf.Uint64()))
+		if scanEvery > 0 && len(trace)%scanEvery == 0 {
+			for i := 0; i < scanLen; i++ {
+				trace = append(trace, fmt.Sprintf("scan_%d", scan))
+				scan++
+			}
+		}
+	}
+	return trace
+}
+
+func TestARC(t *testing.T) {
+	t.Run("t1_to_t2", func(t *testing.T) {
+		// 第二次访问后节点从t1进入t2
+		lru := newPolicyLRU(4, 1, PolicyARC)
+		lru.Add("key1", []byte("val1"), 0)
+		assert.Equal(t, uint32(1), lru.segLen[segRecent])
+		lru.Get("key1")
+		assert.Equal(t, uint32(0), lru.segLen[segRecent])
+		assert.Equal(t, uint32(1), lru.segLen[segFrequent])
+		assert.Equal(t, uint32(1), lru.Len())
+		assert.Equal(t, uint32(4), lru.Cap())
+	})
+
+	t.Run("evict_t1_first", func(t *testing.T) {
+		// t1超过目标大小时，先驱逐t1中最旧的节点
+		lru := newPolicyLRU(3, 1, PolicyARC)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Add("key4", []byte("val4"), 0)
+		_, ok, _ := lru.Get("key2")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, uint32(1), lru.arc.b1.Len())
+	})
+
+	t.Run("ghost_hit", func(t *testing.T) {
+		// 命中b1的key重新写入时直接进入t2，并增大t1的目标大小
+		lru := newPolicyLRU(2, 1, PolicyARC)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0) // key1进入b1
+		assert.Equal(t, uint32(1), lru.arc.b1.Len())
+		lru.Add("key1", []byte("val1"), 0)
+		assert.Equal(t, uint32(1), lru.arc.p)
+		assert.Equal(t, uint32(1), lru.segLen[segFrequent])
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("priority_tie_breaker", func(t *testing.T) {
+		// 同一个列表中，低优先级的节点先被驱逐
+		lru := newPolicyLRU(2, 2, PolicyARC)
+		lru.Add("low", []byte("l"), 0)
+		lru.Add("high", []byte("h"), 1)
+		lru.Add("new", []byte("n"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("high")
+		assert.True(t, ok)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		// 主动删除不进入ghost列表
+		lru := newPolicyLRU(2, 1, PolicyARC)
+		lru.Add("key1", []byte("val1"), 0)
+		_, ok, _ := lru.Remove("key1")
+		assert.True(t, ok)
+		assert.Equal(t, uint32(0), lru.segLen[segRecent])
+		assert.Equal(t, uint32(0), lru.arc.b1.Len())
+	})
+}
+
+func TestARC_HitRatio(t *testing.T) {
+	traces := []struct {
+		name  string
+		trace []string
+	}{
+		{"zipf", zipfTrace(200000, 5000, 0, 0)},
+		{"zipf_with_scan", zipfTrace(200000, 5000, 1000, 500)},
+	}
+	for _, tt := range traces {
+		t.Run(tt.name, func(t *testing.T) {
+			lruRatio := hitRatio(newPolicyLRU(500, 1, PolicyLRU), tt.trace)
+			arcRatio := hitRatio(newPolicyLRU(500, 1, PolicyARC), tt.trace)
+			t.Logf("lru: %.4f arc: %.4f", lruRatio, arcRatio)
+			assert.True(t, arcRatio >= lruRatio)
+		})
+	}
+}
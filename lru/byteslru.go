@@ -0,0 +1,371 @@
+package lru
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+const defaultSlabSize = 1 << 20
+
+// probeSlab marks the ref of the key being looked up, its bytes are in
+// BytesLRU.probe instead of a slab.
+const probeSlab = math.MaxUint32
+
+// bytesRef locates a key or a value inside the slabs of a BytesLRU, it holds
+// no pointer so the arena of a BytesLRU is not scanned by the gc.
+type bytesRef struct {
+	slab uint32
+	off  uint32
+	len  uint32
+}
+
+// BytesOptions holds the settings of NewBytesLRU.
+type BytesOptions struct {
+	// SlabSize is the size of the buffers the keys and values are copied to,
+	// 1MB by default. Larger items get a slab of their own.
+	SlabSize int
+	Policy   Policy
+	// OnEvicted is called with the evicted key and value, the slices are only
+	// valid during the call.
+	OnEvicted func(key, value []byte)
+	// PageSize enables memcached-like size classes if > 0, every item is
+	// stored in a chunk of its class, the classes get pages of PageSize.
+	PageSize int
+	// GrowthFactor is the ratio of the chunk sizes of two adjacent classes,
+	// 1.25 by default.
+	GrowthFactor float64
+	// MinChunkSize is the chunk size of the smallest class, 48 by default.
+	MinChunkSize int
+	// MaxMemory limits the pages of the size classes, a class without free
+	// chunk evicts its least recent item once the limit is reached. No limit
+	// if 0.
+	MaxMemory int64
+}
+
+// BytesStats reports the memory held by the slabs of a BytesLRU.
+type BytesStats struct {
+	Slabs     int
+	LiveBytes uint64 // bytes of the keys and values in the cache
+	DeadBytes uint64 // bytes of removed entries, freed by Compact
+}
+
+// BytesLRU is a cache of string keys and []byte values which copies the keys
+// and values into large slabs, the entries only keep offsets and lengths.
+// Removed entries leave holes in the slabs, which are compacted when they
+// take more room than the live entries. All methods take one mutex.
+type BytesLRU struct {
+	mu        sync.Mutex
+	lru       *LRU[bytesRef, bytesRef]
+	slabs     [][]byte
+	slabSize  int
+	total     uint64 // bytes appended to the slabs
+	live      uint64
+	probe     string
+	onEvicted func(key, value []byte)
+	classes   *slabClasses // nil unless BytesOptions.PageSize is set
+	chunkKey  bytesRef     // key ref of the item being added to a chunk
+}
+
+// bytesHasher resolves the refs of a BytesLRU to compare the bytes behind them.
+type bytesHasher struct {
+	b *BytesLRU
+}
+
+func (h bytesHasher) Hash(r bytesRef) uint64 {
+	return xxhash.Sum64(h.b.view(r))
+}
+
+func (h bytesHasher) Equal(a, b bytesRef) bool {
+	return a.len == b.len && bytes.Equal(h.b.view(a), h.b.view(b))
+}
+
+// Clone copies the key of a new entry into the slabs.
+func (h bytesHasher) Clone(r bytesRef) bytesRef {
+	if r.slab != probeSlab {
+		return r
+	}
+	if h.b.classes != nil {
+		return h.b.chunkKey
+	}
+	return h.b.store(h.b.view(r))
+}
+
+func NewBytesLRU(capacity int, maxPriority byte, opts BytesOptions) (*BytesLRU, error) {
+	if opts.SlabSize <= 0 {
+		opts.SlabSize = defaultSlabSize
+	}
+	if opts.SlabSize > math.MaxUint32 {
+		return nil, errors.New("SlabSizeTooLarge")
+	}
+	b := &BytesLRU{
+		slabSize:  opts.SlabSize,
+		onEvicted: opts.OnEvicted,
+	}
+	if opts.PageSize > 0 {
+		classes, err := newSlabClasses(opts.PageSize, opts.GrowthFactor, opts.MinChunkSize, opts.MaxMemory)
+		if err != nil {
+			return nil, err
+		}
+		b.classes = classes
+	}
+	lru, err := NewPriorityLRUWithHasher[bytesRef, bytesRef](capacity, maxPriority, bytesHasher{b: b}, Options[bytesRef, bytesRef]{
+		Policy:    opts.Policy,
+		OnEvicted: b.evicted,
+		// the slabs are reclaimed by the callback before the chunk is reused
+		EvictDelivery: DeliverInLock,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.lru = lru
+	return b, nil
+}
+
+func (b *BytesLRU) view(r bytesRef) []byte {
+	if r.slab == probeSlab {
+		return unsafe.Slice(unsafe.StringData(b.probe), len(b.probe))
+	}
+	return b.slabs[r.slab][r.off : r.off+r.len : r.off+r.len]
+}
+
+// store copies p to the end of the last slab, or to a new one if it does not fit.
+func (b *BytesLRU) store(p []byte) bytesRef {
+	last := len(b.slabs) - 1
+	if last < 0 || cap(b.slabs[last])-len(b.slabs[last]) < len(p) {
+		size := b.slabSize
+		if len(p) > size {
+			size = len(p)
+		}
+		b.slabs = append(b.slabs, make([]byte, 0, size))
+		last++
+	}
+	r := bytesRef{slab: uint32(last), off: uint32(len(b.slabs[last])), len: uint32(len(p))}
+	b.slabs[last] = append(b.slabs[last], p...)
+	b.total += uint64(len(p))
+	return r
+}
+
+// setProbe makes key the key looked up by the next call of the inner lru.
+func (b *BytesLRU) setProbe(key string) bytesRef {
+	b.probe = key
+	return bytesRef{slab: probeSlab, len: uint32(len(key))}
+}
+
+func (b *BytesLRU) evicted(k, v bytesRef) bool {
+	b.live -= uint64(k.len) + uint64(v.len)
+	if b.onEvicted != nil {
+		b.onEvicted(b.view(k), b.view(v))
+	}
+	if b.classes != nil {
+		b.classes.classes[b.classes.pageClass[k.slab]].evictions++
+		b.freeChunk(k)
+	}
+	return true
+}
+
+// Add copies key and value into the cache.
+func (b *BytesLRU) Add(key string, value []byte, priority byte) error {
+	if uint64(len(key)) > math.MaxUint32 || uint64(len(value)) > math.MaxUint32 {
+		return errors.New("add err: ItemTooLarge")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.setProbe(key)
+	defer b.setProbe("")
+	if b.classes != nil {
+		return b.addChunk(key, value, probe, priority)
+	}
+	e := b.peek(probe)
+	v := b.store(value)
+	var old bytesRef
+	if e != nil {
+		old = e.Value
+	}
+	err := b.lru.Add(probe, v, priority)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		b.live -= uint64(old.len)
+	} else {
+		b.live += uint64(len(key))
+	}
+	b.live += uint64(v.len)
+	b.maybeCompact()
+	return nil
+}
+
+// addChunk stores key and value in one chunk of their size class.
+func (b *BytesLRU) addChunk(key string, value []byte, probe bytesRef, priority byte) error {
+	c, err := b.classes.classOf(len(key) + len(value))
+	if err != nil {
+		return fmt.Errorf("add err: %s", err.Error())
+	}
+	page, off, err := b.allocChunk(c)
+	if err != nil {
+		return fmt.Errorf("add err: %s", err.Error())
+	}
+	copy(b.slabs[page][off:], key)
+	copy(b.slabs[page][off+uint32(len(key)):], value)
+	k := bytesRef{slab: page, off: off, len: uint32(len(key))}
+	v := bytesRef{slab: page, off: off + k.len, len: uint32(len(value))}
+	// an existing entry takes the key of the new chunk before the update, so
+	// the old chunk can be freed
+	e := b.peek(probe)
+	var old *jlist.Entry[bytesRef, bytesRef]
+	var oldKey, oldValue bytesRef
+	if e != nil {
+		old, oldKey, oldValue = e, e.Key, e.Value
+		e.Key = k
+	}
+	b.chunkKey = k
+	err = b.lru.Add(probe, v, priority)
+	if err != nil {
+		if old != nil {
+			old.Key = oldKey
+		}
+		b.freeChunk(k)
+		return err
+	}
+	if old != nil {
+		b.live -= uint64(oldKey.len) + uint64(oldValue.len)
+		b.freeChunk(oldKey)
+	}
+	b.live += uint64(k.len) + uint64(v.len)
+	return nil
+}
+
+// peek returns the entry of key without touching it. The entry stays valid
+// while b.mu is held.
+func (b *BytesLRU) peek(key bytesRef) *jlist.Entry[bytesRef, bytesRef] {
+	hashId, bukPos := b.lru.rlockKey(key)
+	defer b.lru.RUnlock()
+	e, ok, err := b.lru.getEntryInBuk(bukPos, hashId, key)
+	if err != nil || !ok {
+		return nil
+	}
+	return e
+}
+
+// Get returns a copy of the value of key.
+func (b *BytesLRU) Get(key string) (value []byte, ok bool, err error) {
+	ok, err = b.View(key, func(v []byte) {
+		value = append(make([]byte, 0, len(v)), v...)
+	})
+	return value, ok, err
+}
+
+// View calls fn with the value of key without copying it. The slice is only
+// valid during fn and must not be modified, fn must not call the cache.
+func (b *BytesLRU) View(key string, fn func(value []byte)) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.setProbe(key)
+	defer b.setProbe("")
+	v, ok, err := b.lru.Get(probe)
+	if err != nil || !ok {
+		return false, err
+	}
+	fn(b.view(v))
+	return true, nil
+}
+
+// Remove removes key from the cache.
+func (b *BytesLRU) Remove(key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.setProbe(key)
+	defer b.setProbe("")
+	v, ok, err := b.lru.Remove(probe)
+	if err != nil || !ok {
+		return false, err
+	}
+	b.live -= uint64(len(key)) + uint64(v.len)
+	if b.classes != nil {
+		b.freeChunk(bytesRef{slab: v.slab, off: v.off - uint32(len(key)), len: uint32(len(key))})
+		return true, nil
+	}
+	b.maybeCompact()
+	return true, nil
+}
+
+func (b *BytesLRU) Len() uint32 {
+	return b.lru.Len()
+}
+
+func (b *BytesLRU) Cap() uint32 {
+	return b.lru.Cap()
+}
+
+func (b *BytesLRU) Metrics() ListMetrics {
+	return b.lru.Metrics()
+}
+
+func (b *BytesLRU) Stats() BytesStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BytesStats{
+		Slabs:     len(b.slabs),
+		LiveBytes: b.live,
+		DeadBytes: b.total - b.live,
+	}
+}
+
+// ClassStats reports the size classes, nil without BytesOptions.PageSize.
+func (b *BytesLRU) ClassStats() []SlabClassStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.classes == nil {
+		return nil
+	}
+	return b.classes.stats()
+}
+
+func (b *BytesLRU) maybeCompact() {
+	dead := b.total - b.live
+	if dead > b.live && dead >= uint64(b.slabSize) {
+		b.compact()
+	}
+}
+
+// Compact copies the live keys and values to new slabs and drops the old ones.
+// The size classes are not compacted, their chunks are reused instead.
+func (b *BytesLRU) Compact() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.classes != nil {
+		return
+	}
+	b.compact()
+}
+
+func (b *BytesLRU) compact() {
+	b.lru.Lock()
+	defer b.lru.Unlock()
+	old := b.slabs
+	b.slabs = nil
+	b.total = 0
+	front := b.lru.ll.Front()
+	if front == nil {
+		return
+	}
+	e := front
+	for {
+		if e.Flag == 0 {
+			e.Key = b.store(old[e.Key.slab][e.Key.off : e.Key.off+e.Key.len])
+			e.Value = b.store(old[e.Value.slab][e.Value.off : e.Value.off+e.Value.len])
+		}
+		next, err := b.lru.ll.Entry(e.Next())
+		if err != nil || next == front {
+			return
+		}
+		e = next
+	}
+}
@@ -0,0 +1,132 @@
+package lru
+
+import (
+	"fmt"
+	jlist "github.com/junjiefly/jlru/list"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+// hasPointers 检查类型中是否包含需要gc扫描的指针
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Ptr, reflect.UnsafePointer, reflect.String, reflect.Slice, reflect.Map,
+		reflect.Chan, reflect.Func, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+func TestBytesLRU(t *testing.T) {
+	t.Run("pointer_free", func(t *testing.T) {
+		assert.False(t, hasPointers(reflect.TypeOf(jlist.Entry[bytesRef, bytesRef]{})))
+	})
+
+	t.Run("add_get", func(t *testing.T) {
+		// 写入后调用方修改缓冲区不影响缓存中的值
+		b, err := NewBytesLRU(10, 1, BytesOptions{SlabSize: 64})
+		assert.NoError(t, err)
+		value := []byte("val1")
+		assert.NoError(t, b.Add("key1", value, 0))
+		copy(value, "xxxx")
+		got, ok, err := b.Get("key1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("val1"), got)
+		_, ok, _ = b.Get("key2")
+		assert.False(t, ok)
+		assert.Equal(t, uint64(8), b.Stats().LiveBytes)
+	})
+
+	t.Run("view", func(t *testing.T) {
+		// View不拷贝，直接读取slab中的数据
+		b, _ := NewBytesLRU(10, 1, BytesOptions{SlabSize: 64})
+		b.Add("key1", []byte("val1"), 0)
+		var seen []byte
+		ok, err := b.View("key1", func(v []byte) {
+			seen = v
+		})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("val1"), seen)
+		assert.Equal(t, &b.slabs[0][0], &seen[0]) // 值先于key写入slab
+	})
+
+	t.Run("update_remove", func(t *testing.T) {
+		// 更新和删除产生的空洞计入DeadBytes
+		b, _ := NewBytesLRU(10, 1, BytesOptions{SlabSize: 1024})
+		b.Add("key1", []byte("val1"), 0)
+		b.Add("key1", []byte("value1"), 0)
+		got, _, _ := b.Get("key1")
+		assert.Equal(t, []byte("value1"), got)
+		assert.Equal(t, uint32(1), b.Len())
+		assert.Equal(t, BytesStats{Slabs: 1, LiveBytes: 10, DeadBytes: 4}, b.Stats())
+		ok, err := b.Remove("key1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, BytesStats{Slabs: 1, LiveBytes: 0, DeadBytes: 14}, b.Stats())
+		ok, _ = b.Remove("key1")
+		assert.False(t, ok)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		// 驱逐回调拿到key和value，空间计入DeadBytes
+		var evicted []string
+		b, _ := NewBytesLRU(2, 1, BytesOptions{
+			SlabSize: 1024,
+			OnEvicted: func(key, value []byte) {
+				evicted = append(evicted, string(key)+"="+string(value))
+			},
+		})
+		b.Add("key1", []byte("val1"), 0)
+		b.Add("key2", []byte("val2"), 0)
+		b.Add("key3", []byte("val3"), 0)
+		assert.Equal(t, []string{"key1=val1"}, evicted)
+		assert.Equal(t, uint64(16), b.Stats().LiveBytes)
+		assert.Equal(t, uint64(8), b.Stats().DeadBytes)
+	})
+
+	t.Run("large_value", func(t *testing.T) {
+		// 超过slab大小的值独占一个slab
+		b, _ := NewBytesLRU(10, 1, BytesOptions{SlabSize: 16})
+		large := make([]byte, 100)
+		large[99] = 1
+		b.Add("key1", large, 0)
+		got, ok, _ := b.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, large, got)
+		assert.Equal(t, 2, b.Stats().Slabs)
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		// 空洞多于存活数据时自动整理，数据不变
+		b, _ := NewBytesLRU(100, 1, BytesOptions{SlabSize: 64})
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("key_%d", i%20)
+			assert.NoError(t, b.Add(key, []byte(fmt.Sprintf("value_%d", i)), 0))
+		}
+		stats := b.Stats()
+		assert.True(t, stats.DeadBytes <= stats.LiveBytes+64)
+		for i := 180; i < 200; i++ {
+			got, ok, _ := b.Get(fmt.Sprintf("key_%d", i%20))
+			assert.True(t, ok)
+			assert.Equal(t, []byte(fmt.Sprintf("value_%d", i)), got)
+		}
+		b.Compact()
+		stats = b.Stats()
+		assert.Equal(t, uint64(0), stats.DeadBytes)
+		assert.Equal(t, 5, stats.Slabs)
+		got, _, _ := b.Get("key_3")
+		assert.Equal(t, []byte("value_183"), got)
+	})
+}
@@ -0,0 +1,85 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+	"sync/atomic"
+)
+
+// In clock mode the entries stay in the same priority bands as the strict lru,
+// but a hit only sets the reference bit of the entry under the read lock.
+// The eviction scan clears the bit and gives the entry a second chance at the
+// front of its band. The reference bits are kept beside the arena, by idx, so
+// the other policies do not pay for them. sieve uses them as visited bits.
+
+func (lru *LRU[K, V]) reference(e *jlist.Entry[K, V]) {
+	ref := &lru.refs[e.Idx()]
+	if atomic.LoadUint32(ref) == 0 {
+		atomic.StoreUint32(ref, 1)
+	}
+}
+
+func (lru *LRU[K, V]) clockOldest() *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		markNode, err := lru.getPriorityMarkNode(i)
+		if err != nil {
+			return nil
+		}
+		frontNode, err := lru.getPriorityMarkNode(i + 1)
+		if err != nil {
+			return nil
+		}
+		for {
+			e, err := lru.ll.Entry(markNode.Prev())
+			if err != nil {
+				return nil
+			}
+			if e.Flag != 0 {
+				break
+			}
+			if lru.refs[e.Idx()] == 0 {
+				return e
+			}
+			lru.refs[e.Idx()] = 0
+			if lru.ll.MoveAfter(e, frontNode) != nil {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// clockPeek returns the entry clockOldest would evict: the first one not
+// referenced from the back of the lowest band, or the back one if all of them
+// were referenced.
+func (lru *LRU[K, V]) clockPeek() *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		markNode, err := lru.getPriorityMarkNode(i)
+		if err != nil {
+			return nil
+		}
+		var back *jlist.Entry[K, V]
+		idx := markNode.Prev()
+		for {
+			e, err := lru.ll.Entry(idx)
+			if err != nil {
+				return nil
+			}
+			if e.Flag != 0 {
+				break
+			}
+			if lru.refs[idx] == 0 {
+				return e
+			}
+			if back == nil {
+				back = e
+			}
+			idx = e.Prev()
+		}
+		if back != nil {
+			return back
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestClockLRU(t *testing.T) {
+	t.Run("second_chance", func(t *testing.T) {
+		// 被访问过的节点在淘汰扫描时获得第二次机会
+		lru, err := NewPriorityLRUWithOptions[string, []byte](3, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			Policy:   PolicyClock,
+		})
+		assert.NoError(t, err)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key1") // key1置引用位
+		assert.True(t, ok)
+		lru.Add("key4", []byte("val4"), 0) // 驱逐key2
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("key2")
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), lru.Metrics().Evictions)
+	})
+
+	t.Run("all_referenced", func(t *testing.T) {
+		// 所有节点都被访问过时，清除引用位后驱逐最旧的节点
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			Policy:   PolicyClock,
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Get("key2")
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key1")
+		assert.False(t, ok)
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("priority_band", func(t *testing.T) {
+		// 引用位只在优先级内部生效，低优先级仍先被驱逐
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 2, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			Policy:   PolicyClock,
+		})
+		lru.Add("high", []byte("h"), 1)
+		lru.Add("low", []byte("l"), 0)
+		lru.Get("low")
+		lru.Add("new", []byte("n"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("high")
+		assert.True(t, ok)
+	})
+
+	t.Run("unknown_policy", func(t *testing.T) {
+		lru, err := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			Policy:   Policy(255),
+		})
+		assert.Error(t, err)
+		assert.Nil(t, lru)
+	})
+}
+
+func TestClockLRU_ConcurrentAccess(t *testing.T) {
+	lru, _ := NewPriorityLRUWithOptions[string, []byte](10, 2, Options[string, []byte]{
+		HashFunc: HashXXHASH,
+		Policy:   PolicyClock,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", idx)
+			lru.Add(key, []byte(key), byte(idx%3))
+			lru.Get(key)
+			if idx%5 == 0 {
+				lru.Remove(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, lru.Len() <= 10)
+}
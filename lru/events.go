@@ -0,0 +1,124 @@
+package lru
+
+import (
+	"sync"
+)
+
+// EventOp is the kind of a change of the cache.
+type EventOp byte
+
+const (
+	// EventAdd is a new key stored by Add or AddToBack.
+	EventAdd EventOp = iota + 1
+	// EventUpdate is a key whose value or priority was replaced.
+	EventUpdate
+	// EventRemove is a key dropped by Remove or Purge.
+	EventRemove
+	// EventEvict is a key evicted to make room, or by RemoveOldest.
+	EventEvict
+	// EventExpire is reserved for keys dropped when they expire.
+	EventExpire
+	// EventGap tells a subscriber that fell behind that the events from Seq
+	// up to the next delivered one were lost.
+	EventGap
+)
+
+const defaultEventBuffer = 256
+
+// Event is a change of the cache sent to the subscribers. Seq grows by one
+// for every change published while there is a subscriber.
+type Event[K any, V any] struct {
+	Op       EventOp
+	Key      K
+	Value    V
+	Priority byte
+	Seq      uint64
+}
+
+type subscriber[K any, V any] struct {
+	ch  chan Event[K, V]
+	gap uint64 // seq of the first lost event, 0 if none
+}
+
+// send never blocks, the events which do not fit are replaced by a gap marker.
+func (s *subscriber[K, V]) send(ev Event[K, V]) {
+	if s.gap != 0 {
+		select {
+		case s.ch <- Event[K, V]{Op: EventGap, Seq: s.gap}:
+			s.gap = 0
+		default:
+			return
+		}
+	}
+	select {
+	case s.ch <- ev:
+	default:
+		s.gap = ev.Seq
+	}
+}
+
+// Subscribe returns a stream of the changes of the cache, buffering up to
+// bufferSize events, 256 if bufferSize is not positive. A subscriber which
+// does not keep up gets an EventGap instead of the lost events, it never
+// blocks the cache. cancel closes the channel, so does Close of the cache.
+func (lru *LRU[K, V]) Subscribe(bufferSize int) (<-chan Event[K, V], func()) {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBuffer
+	}
+	s := &subscriber[K, V]{ch: make(chan Event[K, V], bufferSize)}
+	lru.Lock()
+	defer lru.Unlock()
+	if lru.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+	lru.subs = append(lru.subs, s)
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			lru.Lock()
+			defer lru.Unlock()
+			lru.unsubscribe(s)
+		})
+	}
+}
+
+func (lru *LRU[K, V]) unsubscribe(s *subscriber[K, V]) {
+	for i, sub := range lru.subs {
+		if sub == s {
+			lru.subs = append(lru.subs[:i], lru.subs[i+1:]...)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// changed sends a change to the subscribers and to the watchers of key, must
+// be called with the write lock held.
+func (lru *LRU[K, V]) changed(op EventOp, hashId uint64, key K, value V, priority byte) {
+	lru.publish(op, key, value, priority)
+	if len(lru.watches) != 0 {
+		lru.notifyWatchers(op, hashId, key, value, priority)
+	}
+}
+
+// publish sends a change to the subscribers, must be called with the write
+// lock held.
+func (lru *LRU[K, V]) publish(op EventOp, key K, value V, priority byte) {
+	if len(lru.subs) == 0 {
+		return
+	}
+	lru.seq++
+	ev := Event[K, V]{Op: op, Key: key, Value: value, Priority: priority, Seq: lru.seq}
+	for _, s := range lru.subs {
+		s.send(ev)
+	}
+}
+
+// closeSubscribers ends the streams of a closed cache.
+func (lru *LRU[K, V]) closeSubscribers() {
+	for _, s := range lru.subs {
+		close(s.ch)
+	}
+	lru.subs = nil
+}
@@ -0,0 +1,84 @@
+package lru
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func drainEvents[K any, V any](ch <-chan Event[K, V]) []Event[K, V] {
+	var events []Event[K, V]
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Run("ops", func(t *testing.T) {
+		// 每次变更产生一个事件，序号连续递增
+		lru, _ := NewPriorityLRU[string, string](2, 2, nil, nil)
+		lru.Add("key0", "val0", 0) // 订阅前的变更不发送
+		ch, cancel := lru.Subscribe(16)
+		defer cancel()
+		lru.Add("key1", "val1", 1)
+		lru.Add("key1", "val2", 0)
+		lru.AddToBack("key2", "val3", 0) // 驱逐key0
+		lru.Get("key2")
+		lru.Remove("key2")
+		assert.NoError(t, lru.Purge())
+		want := []Event[string, string]{
+			{Op: EventAdd, Key: "key1", Value: "val1", Priority: 1, Seq: 1},
+			{Op: EventUpdate, Key: "key1", Value: "val2", Priority: 0, Seq: 2},
+			{Op: EventEvict, Key: "key0", Value: "val0", Priority: 0, Seq: 3},
+			{Op: EventAdd, Key: "key2", Value: "val3", Priority: 0, Seq: 4},
+			{Op: EventRemove, Key: "key2", Value: "val3", Priority: 0, Seq: 5},
+			{Op: EventRemove, Key: "key1", Value: "val2", Priority: 0, Seq: 6},
+		}
+		assert.Equal(t, want, drainEvents(ch))
+	})
+
+	t.Run("gap", func(t *testing.T) {
+		// 缓冲区满时不阻塞写入，丢失的事件用gap标记代替
+		lru, _ := NewPriorityLRU[int, int](10, 1, nil, nil)
+		ch, cancel := lru.Subscribe(2)
+		defer cancel()
+		for i := 1; i <= 5; i++ {
+			assert.NoError(t, lru.Add(i, i, 0))
+		}
+		events := drainEvents(ch)
+		assert.Equal(t, []uint64{1, 2}, []uint64{events[0].Seq, events[1].Seq})
+		lru.Add(6, 6, 0)
+		events = drainEvents(ch)
+		assert.Equal(t, []Event[int, int]{
+			{Op: EventGap, Seq: 3},
+			{Op: EventAdd, Key: 6, Value: 6, Seq: 6},
+		}, events)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		// cancel和Close关闭channel
+		lru, _ := NewPriorityLRU[int, int](10, 1, nil, nil)
+		ch1, cancel1 := lru.Subscribe(0)
+		ch2, cancel2 := lru.Subscribe(0)
+		cancel1()
+		cancel1()
+		lru.Add(1, 1, 0)
+		_, ok := <-ch1
+		assert.False(t, ok)
+		assert.Len(t, drainEvents(ch2), 1)
+		assert.NoError(t, lru.Close())
+		_, ok = <-ch2
+		assert.False(t, ok)
+		cancel2()
+		ch3, _ := lru.Subscribe(0)
+		_, ok = <-ch3
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,151 @@
+package lru
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictDelivery selects when OnEvicted and OnEvictedReason are called.
+type EvictDelivery byte
+
+const (
+	// DeliverAfterUnlock collects the evicted entries during the call and
+	// delivers them after the lock is released, so the callbacks may use the
+	// cache. OnEvicted cannot keep an entry, its result is ignored.
+	DeliverAfterUnlock EvictDelivery = iota
+	// DeliverInLock calls the callbacks while the lock is held, OnEvicted may
+	// keep an entry by returning false. The callbacks must not use the cache.
+	DeliverInLock
+	// DeliverAsync hands the evicted entries to a worker goroutine through a
+	// queue of Options.EvictQueueSize, see Options.Backpressure.
+	DeliverAsync
+)
+
+// Backpressure tells DeliverAsync what to do when the queue is full.
+type Backpressure byte
+
+const (
+	// BackpressureBlock waits for room in the queue, the lock is not held.
+	BackpressureBlock Backpressure = iota
+	// BackpressureDrop drops the entry and counts it in DroppedEvictions.
+	BackpressureDrop
+	// BackpressureCallerRuns calls the callbacks in the goroutine of the caller.
+	BackpressureCallerRuns
+)
+
+const defaultEvictQueueSize = 1024
+
+type evictedEntry[K any, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// evictQueue feeds the evicted entries to a single worker, so they are
+// delivered in eviction order unless the queue overflows to the caller.
+type evictQueue[K any, V any] struct {
+	mu      sync.RWMutex // guards closed against the sends
+	closed  bool
+	ch      chan evictedEntry[K, V]
+	done    chan struct{}
+	policy  Backpressure
+	dropped *uint64
+}
+
+func newEvictQueue[K any, V any](size int, policy Backpressure, dropped *uint64, deliver func(evictedEntry[K, V])) *evictQueue[K, V] {
+	if size <= 0 {
+		size = defaultEvictQueueSize
+	}
+	q := &evictQueue[K, V]{
+		ch:      make(chan evictedEntry[K, V], size),
+		done:    make(chan struct{}),
+		policy:  policy,
+		dropped: dropped,
+	}
+	go func() {
+		defer close(q.done)
+		for ev := range q.ch {
+			deliver(ev)
+		}
+	}()
+	return q
+}
+
+// push queues ev and reports whether the caller has to deliver it itself.
+func (q *evictQueue[K, V]) push(ev evictedEntry[K, V]) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return true
+	}
+	if q.policy == BackpressureBlock {
+		q.ch <- ev
+		return false
+	}
+	select {
+	case q.ch <- ev:
+		return false
+	default:
+	}
+	if q.policy == BackpressureDrop {
+		atomic.AddUint64(q.dropped, 1)
+		return false
+	}
+	return true
+}
+
+// stop waits for the worker to deliver the queued entries.
+func (q *evictQueue[K, V]) stop() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.ch)
+	q.mu.Unlock()
+	<-q.done
+}
+
+// evicted is called under the lock with an entry which left the cache.
+func (lru *LRU[K, V]) evicted(key K, value V, reason EvictReason) {
+	if lru.delivery == DeliverInLock {
+		if lru.OnEvictedReason != nil {
+			lru.OnEvictedReason(key, value, reason)
+		}
+		return
+	}
+	lru.pending = append(lru.pending, evictedEntry[K, V]{key: key, value: value, reason: reason})
+}
+
+// unlock releases the write lock and the key lock of the writer, runs the
+// saves queued under it, then delivers the entries evicted under it.
+func (lru *LRU[K, V]) unlock() {
+	if len(lru.pending) == 0 && len(lru.saves) == 0 && lru.keyLock == nil {
+		lru.Unlock()
+		return
+	}
+	pending, saves, keyLock := lru.pending, lru.saves, lru.keyLock
+	lru.pending, lru.saves, lru.keyLock = nil, nil, nil
+	lru.Unlock()
+	if keyLock != nil {
+		keyLock.Unlock()
+	}
+	if len(saves) > 0 {
+		lru.runSaves(saves)
+	}
+	for _, ev := range pending {
+		if lru.evictQueue == nil || lru.evictQueue.push(ev) {
+			lru.deliver(ev)
+		}
+	}
+}
+
+func (lru *LRU[K, V]) deliver(ev evictedEntry[K, V]) {
+	if lru.OnEvicted != nil {
+		lru.OnEvicted(ev.key, ev.value)
+	}
+	if lru.OnEvictedReason != nil {
+		lru.OnEvictedReason(ev.key, ev.value, ev.reason)
+	}
+}
@@ -0,0 +1,131 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEvictDelivery(t *testing.T) {
+	t.Run("after_unlock", func(t *testing.T) {
+		// 回调在释放锁之后执行，可以访问缓存
+		var lru *LRU[string, []byte]
+		var evicted []string
+		lru, _ = NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			OnEvicted: func(key string, value []byte) bool {
+				evicted = append(evicted, key)
+				lru.Len()
+				_, ok, _ := lru.Get(key)
+				assert.False(t, ok)
+				return false // 返回值被忽略
+			},
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		assert.Equal(t, []string{"key1"}, evicted)
+		assert.True(t, lru.RemoveOldest())
+		assert.Equal(t, []string{"key1", "key2"}, evicted)
+		assert.NoError(t, lru.Purge())
+		assert.Equal(t, []string{"key1", "key2", "key3"}, evicted)
+	})
+
+	t.Run("in_lock", func(t *testing.T) {
+		// 锁内回调返回false时保留节点
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc:      HashXXHASH,
+			EvictDelivery: DeliverInLock,
+			OnEvicted: func(key string, value []byte) bool {
+				return key != "key1"
+			},
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.RemoveOldest()
+		_, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("legacy_in_lock", func(t *testing.T) {
+		// NewPriorityLRU默认锁内回调，返回false仍可保留节点
+		lru, _ := NewPriorityLRU[string, []byte](2, 1, HashXXHASH, func(key string, value []byte) bool {
+			return key != "key1"
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.RemoveOldest()
+		_, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("async", func(t *testing.T) {
+		// 异步回调按驱逐顺序执行，Close等待队列中的回调完成
+		var evicted []string
+		var reasons []EvictReason
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc:      HashXXHASH,
+			EvictDelivery: DeliverAsync,
+			OnEvictedReason: func(key string, value []byte, reason EvictReason) {
+				evicted = append(evicted, key)
+				reasons = append(reasons, reason)
+			},
+		})
+		for i := 0; i < 10; i++ {
+			lru.Add(fmt.Sprintf("key%d", i), []byte("val"), 0)
+		}
+		assert.NoError(t, lru.Purge())
+		assert.NoError(t, lru.Close())
+		assert.Equal(t, []string{"key0", "key1", "key2", "key3", "key4", "key5", "key6", "key7", "key8", "key9"}, evicted)
+		assert.Equal(t, EvictCapacity, reasons[0])
+		assert.Equal(t, EvictCleared, reasons[9])
+	})
+
+	t.Run("backpressure", func(t *testing.T) {
+		// 队列满时按backpressure策略丢弃或在调用方执行
+		for _, policy := range []Backpressure{BackpressureDrop, BackpressureCallerRuns} {
+			block := make(chan struct{})
+			started := make(chan struct{})
+			delivered := 0
+			lru, _ := NewPriorityLRUWithOptions[int, int](1, 1, Options[int, int]{
+				EvictDelivery:  DeliverAsync,
+				EvictQueueSize: 1,
+				Backpressure:   policy,
+				OnEvicted: func(key int, value int) bool {
+					if key == 0 {
+						close(started)
+						<-block
+					}
+					delivered++
+					return true
+				},
+			})
+			lru.Add(0, 0, 0)
+			lru.Add(1, 1, 0) // 0被worker取出后阻塞
+			<-started
+			lru.Add(2, 2, 0) // 1进入队列
+			lru.Add(3, 3, 0) // 队列已满
+			if policy == BackpressureDrop {
+				assert.Equal(t, uint64(1), lru.Metrics().DroppedEvictions)
+				assert.Equal(t, 0, delivered)
+			} else {
+				assert.Equal(t, uint64(0), lru.Metrics().DroppedEvictions)
+				assert.Equal(t, 1, delivered)
+			}
+			close(block)
+			assert.NoError(t, lru.Close())
+			if policy == BackpressureDrop {
+				assert.Equal(t, 2, delivered)
+			} else {
+				assert.Equal(t, 3, delivered)
+			}
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := NewPriorityLRUWithOptions[int, int](1, 1, Options[int, int]{EvictDelivery: DeliverAsync + 1})
+		assert.Error(t, err)
+	})
+}
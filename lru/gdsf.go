@@ -0,0 +1,82 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+// gdsf scores every entry with clock + (priority+1) * hits / size and evicts
+// the entry with the lowest score. The clock is raised to the score of every
+// evicted entry, so entries which are not hit any more age out over time.
+// The scores are kept in an indexed heap over the arena indices. Like the
+// other policies, entries of the highest priority are never evicted.
+type gdsfState struct {
+	heap  *idxHeap
+	score []float64 // arena idx -> score
+	clock float64
+}
+
+func newGdsfState() *gdsfState {
+	g := &gdsfState{
+		score: []float64{},
+	}
+	g.heap = newIdxHeap(func(a, b uint32) bool {
+		return g.score[a] < g.score[b]
+	})
+	return g
+}
+
+func (g *gdsfState) grow(n uint32, max uint32) {
+	g.score = growSlice(g.score, n, max)
+	g.heap.grow(n, max)
+}
+
+func (g *gdsfState) reset() {
+	g.heap.reset()
+	g.clock = 0
+}
+
+func (lru *LRU[K, V]) entrySize(e *jlist.Entry[K, V]) uint64 {
+	if lru.sizes == nil {
+		return 1
+	}
+	if size := lru.sizes[e.Idx()]; size > 0 {
+		return size
+	}
+	return 1
+}
+
+// gdsfUpdate scores e again after its priority, hits or value changed.
+func (lru *LRU[K, V]) gdsfUpdate(e *jlist.Entry[K, V]) {
+	g := lru.gdsf
+	if e.Priority >= lru.maxPriority {
+		g.heap.remove(e.Idx())
+		return
+	}
+	g.score[e.Idx()] = g.clock + float64(e.Priority+1)*float64(lru.freqs[e.Idx()])/float64(lru.entrySize(e))
+	g.heap.fix(e.Idx())
+}
+
+func (lru *LRU[K, V]) gdsfHit(e *jlist.Entry[K, V]) {
+	lru.freqs[e.Idx()]++
+	lru.gdsfUpdate(e)
+}
+
+func (lru *LRU[K, V]) gdsfForget(e *jlist.Entry[K, V], evict bool) {
+	g := lru.gdsf
+	if evict && g.heap.contains(e.Idx()) {
+		g.clock = g.score[e.Idx()]
+	}
+	g.heap.remove(e.Idx())
+}
+
+func (lru *LRU[K, V]) gdsfOldest() *jlist.Entry[K, V] {
+	idx, ok := lru.gdsf.heap.min()
+	if !ok {
+		return nil
+	}
+	e, err := lru.ll.Entry(idx)
+	if err != nil {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,112 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func newGdsfLRU(capacity int, maxPriority byte) *LRU[string, []byte] {
+	lru, _ := NewPriorityLRUWithOptions[string, []byte](capacity, maxPriority, Options[string, []byte]{
+		HashFunc: HashXXHASH,
+		Policy:   PolicyGDSF,
+		SizeFunc: func(key string, value []byte) uint64 {
+			return uint64(len(value))
+		},
+	})
+	return lru
+}
+
+// checkHeap 检查堆的性质以及位置索引
+func checkHeap(t *testing.T, h *idxHeap) {
+	for i, idx := range h.items {
+		assert.Equal(t, uint32(i), h.pos[idx])
+		if i > 0 {
+			assert.False(t, h.less(idx, h.items[(i-1)/2]), "heap order")
+		}
+	}
+}
+
+func TestGDSF(t *testing.T) {
+	t.Run("evict_large_first", func(t *testing.T) {
+		// 访问次数相同时，先驱逐体积大的节点
+		lru := newGdsfLRU(3, 1)
+		lru.Add("blob", make([]byte, 4096), 0)
+		lru.Add("tiny1", []byte("t1"), 0)
+		lru.Add("tiny2", []byte("t2"), 0)
+		lru.Add("tiny3", []byte("t3"), 0)
+		_, ok, _ := lru.Get("blob")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("tiny1")
+		assert.True(t, ok)
+	})
+
+	t.Run("hits_protect", func(t *testing.T) {
+		// 体积相同时，先驱逐访问次数少的节点
+		lru := newGdsfLRU(2, 1)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key2")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+	})
+
+	t.Run("inflation", func(t *testing.T) {
+		// 驱逐后clock提升到被驱逐节点的分数，新节点的分数基于clock
+		lru := newGdsfLRU(1, 1)
+		lru.Add("key1", []byte("12"), 0)
+		assert.Equal(t, float64(0), lru.gdsf.clock)
+		lru.Add("key2", []byte("1234"), 0)
+		assert.Equal(t, 0.5, lru.gdsf.clock)
+		e := findEntry(lru, "key2")
+		assert.Equal(t, 0.75, lru.gdsf.score[e.Idx()])
+		lru.Remove("key2")
+		assert.Equal(t, 0.5, lru.gdsf.clock) // 主动删除不影响clock
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		// 优先级放大分数，最高优先级不会被驱逐
+		lru := newGdsfLRU(2, 2)
+		lru.Add("low", []byte("l"), 0)
+		lru.Add("pinned", []byte("pinned_large_value"), 2)
+		lru.Add("mid", []byte("m"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("pinned")
+		assert.True(t, ok)
+		assert.Equal(t, 1, lru.gdsf.heap.Len())
+	})
+
+	t.Run("random_operations", func(t *testing.T) {
+		lru := newGdsfLRU(20, 3)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("key%d", r.Intn(40))
+			switch r.Intn(5) {
+			case 0:
+				lru.Add(key, make([]byte, r.Intn(100)), byte(r.Intn(4)))
+			case 1:
+				lru.AddToBack(key, make([]byte, r.Intn(100)), byte(r.Intn(4)))
+			case 2:
+				lru.Remove(key)
+			default:
+				lru.Get(key)
+			}
+			checkHeap(t, lru.gdsf.heap)
+		}
+		assert.Equal(t, uint64(0), lru.Metrics().Errors)
+		keys, _, prioritys := lru.Iterate()
+		var heaped int
+		for _, p := range prioritys {
+			if p < 3 {
+				heaped++
+			}
+		}
+		assert.Equal(t, heaped, lru.gdsf.heap.Len())
+		assert.Equal(t, len(keys), int(lru.Len()))
+	})
+}
@@ -0,0 +1,76 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+// ghostList remembers the hashes of recently evicted entries in lru order,
+// with a small value of the policy for every hash. The keys and values of the
+// entries are not kept.
+type ghostList struct {
+	ll    *jlist.List[uint64, uint64]
+	index map[uint64]uint32 // hash -> node idx
+}
+
+func newGhostList(capacity int) *ghostList {
+	return &ghostList{
+		ll:    jlist.NewList[uint64, uint64](capacity),
+		index: make(map[uint64]uint32),
+	}
+}
+
+// reset forgets all the hashes.
+func (g *ghostList) reset() {
+	g.ll.Reset()
+	for hashId := range g.index {
+		delete(g.index, hashId)
+	}
+}
+
+func (g *ghostList) Len() uint32 {
+	return g.ll.Len()
+}
+
+// push adds hashId to the front, the oldest hash is dropped when the list is full.
+func (g *ghostList) push(hashId uint64, value uint64) {
+	if idx, ok := g.index[hashId]; ok {
+		e, err := g.ll.Entry(idx)
+		if err == nil {
+			e.Value = value
+			_ = g.ll.MoveToFront(e)
+		}
+		return
+	}
+	if g.ll.Len() >= g.ll.Cap() {
+		g.popOldest()
+	}
+	e, err := g.ll.PushFront(hashId, value, 0)
+	if err != nil {
+		return
+	}
+	g.index[hashId] = e.Idx()
+}
+
+// remove drops hashId and returns its value if it was in the list.
+func (g *ghostList) remove(hashId uint64) (uint64, bool) {
+	idx, ok := g.index[hashId]
+	if !ok {
+		return 0, false
+	}
+	delete(g.index, hashId)
+	e, err := g.ll.Entry(idx)
+	if err != nil {
+		return 0, false
+	}
+	value, _ := g.ll.Remove(e)
+	return value, true
+}
+
+func (g *ghostList) popOldest() {
+	e := g.ll.Back()
+	if e == nil {
+		return
+	}
+	delete(g.index, e.Key)
+	_, _ = g.ll.Remove(e)
+}
@@ -0,0 +1,150 @@
+package lru
+
+import (
+	"errors"
+	"hash/maphash"
+	"reflect"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/junjiefly/jlru/hashbuilder"
+)
+
+// ErrHashFuncRequired is returned by the constructors when no HashFunc is given
+// and there is no default hasher for the key type.
+var ErrHashFuncRequired = errors.New("HashFuncRequired")
+
+// Integer is the set of the key types hashed by HashInt.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// mix64 is the finalizer of murmur3, every bit of x affects the result.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// HashInt hashes integer keys.
+func HashInt[K Integer](k K) uint32 {
+	return uint32(mix64(uint64(k)))
+}
+
+// HashString hashes string keys, the same as HashXXHASH.
+func HashString[K ~string](k K) uint32 {
+	return uint32(xxhash.Sum64String(string(k)))
+}
+
+// HashBytes returns the hash of string(b) without converting b, so a key
+// built from a byte slice can be hashed from the slice.
+func HashBytes(b []byte) uint32 {
+	return uint32(xxhash.Sum64(b))
+}
+
+// HashID16 hashes 16 byte ids like uuids.
+func HashID16(k [16]byte) uint32 {
+	return uint32(xxhash.Sum64(k[:]))
+}
+
+// HashID32 hashes 32 byte ids like sha256 sums.
+func HashID32(k [32]byte) uint32 {
+	return uint32(xxhash.Sum64(k[:]))
+}
+
+// NewMaphashHasher returns a hasher which feeds the memory of the key to
+// hash/maphash with a random seed. The key type is only checked once here, it
+// must not contain pointers, strings, floats or padding, since keys equal by ==
+// must have the same memory.
+func NewMaphashHasher[K comparable]() (HashKeyCallback64[K], error) {
+	return newMaphashHasher[K]()
+}
+
+func newMaphashHasher[K any]() (HashKeyCallback64[K], error) {
+	if !memHashable(reflect.TypeOf((*K)(nil)).Elem()) {
+		return nil, ErrHashFuncRequired
+	}
+	seed := maphash.MakeSeed()
+	return func(k K) uint64 {
+		b := unsafe.Slice((*byte)(unsafe.Pointer(&k)), unsafe.Sizeof(k))
+		return maphash.Bytes(seed, b)
+	}, nil
+}
+
+// memHashable reports whether the values of t are equal if and only if their
+// memory is equal.
+func memHashable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Array:
+		return memHashable(t.Elem())
+	case reflect.Struct:
+		var size uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" || !memHashable(f.Type) {
+				return false
+			}
+			size += f.Type.Size()
+		}
+		return size == t.Size()
+	}
+	return false
+}
+
+// defaultHashFunc picks a hasher for K when Options.HashFunc is nil, it keeps
+// all the 64 bits of the hashes. Structs holding strings or floats are hashed
+// field by field by hashbuilder.StructHasher.
+func defaultHashFunc[K any]() (HashKeyCallback64[K], error) {
+	t := reflect.TypeOf((*K)(nil)).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		return func(k K) uint64 {
+			return xxhash.Sum64String(*(*string)(unsafe.Pointer(&k)))
+		}, nil
+	case reflect.Int, reflect.Int64:
+		if t.Size() == 8 {
+			return func(k K) uint64 { return mix64(uint64(*(*int64)(unsafe.Pointer(&k)))) }, nil
+		}
+		return func(k K) uint64 { return mix64(uint64(*(*int32)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Int32:
+		return func(k K) uint64 { return mix64(uint64(*(*int32)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Int16:
+		return func(k K) uint64 { return mix64(uint64(*(*int16)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Int8:
+		return func(k K) uint64 { return mix64(uint64(*(*int8)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		if t.Size() == 8 {
+			return func(k K) uint64 { return mix64(*(*uint64)(unsafe.Pointer(&k))) }, nil
+		}
+		return func(k K) uint64 { return mix64(uint64(*(*uint32)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Uint32:
+		return func(k K) uint64 { return mix64(uint64(*(*uint32)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Uint16:
+		return func(k K) uint64 { return mix64(uint64(*(*uint16)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Uint8:
+		return func(k K) uint64 { return mix64(uint64(*(*uint8)(unsafe.Pointer(&k)))) }, nil
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(k K) uint64 {
+				return xxhash.Sum64(unsafe.Slice((*byte)(unsafe.Pointer(&k)), unsafe.Sizeof(k)))
+			}, nil
+		}
+	}
+	if memHashable(t) {
+		return newMaphashHasher[K]()
+	}
+	if t.Kind() == reflect.Struct {
+		hash, err := hashbuilder.StructHasher[K]()
+		if err == nil {
+			return hash, nil
+		}
+	}
+	return nil, ErrHashFuncRequired
+}
@@ -0,0 +1,140 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/junjiefly/jlru/hashbuilder"
+	jlist "github.com/junjiefly/jlru/list"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type tenantKey struct {
+	Tenant  uint32
+	Shard   uint32
+	Version int64
+}
+
+type pathKey string
+
+func TestDefaultHashFunc(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		// 默认hasher与导出的hasher结果一致
+		hashInt, err := defaultHashFunc[int]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashInt(-5), uint32(hashInt(-5)))
+		hashInt8, err := defaultHashFunc[int8]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashInt(int8(-5)), uint32(hashInt8(-5)))
+		hashUint16, err := defaultHashFunc[uint16]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashInt(uint16(7)), uint32(hashUint16(7)))
+		hashPath, err := defaultHashFunc[pathKey]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashXXHASH64("a/b"), hashPath("a/b"))
+		assert.Equal(t, HashString(pathKey("a/b")), uint32(hashPath("a/b")))
+		assert.Equal(t, HashXXHASH("a/b"), HashBytes([]byte("a/b")))
+		hashID, err := defaultHashFunc[[16]byte]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashID16([16]byte{1, 2, 3}), uint32(hashID([16]byte{1, 2, 3})))
+		hashID32, err := defaultHashFunc[[32]byte]()
+		assert.NoError(t, err)
+		assert.Equal(t, HashID32([32]byte{4}), uint32(hashID32([32]byte{4})))
+	})
+
+	t.Run("maphash", func(t *testing.T) {
+		// 不含指针和填充的结构体使用maphash
+		hash, err := defaultHashFunc[tenantKey]()
+		assert.NoError(t, err)
+		assert.Equal(t, hash(tenantKey{1, 2, 3}), hash(tenantKey{1, 2, 3}))
+		assert.NotEqual(t, hash(tenantKey{1, 2, 3}), hash(tenantKey{1, 2, 4}))
+	})
+
+	t.Run("struct_fields", func(t *testing.T) {
+		// 含字符串或填充的结构体按字段哈希
+		hash, err := defaultHashFunc[struct {
+			Tenant uint32
+			Path   string
+		}]()
+		assert.NoError(t, err)
+		assert.Equal(t, hashbuilder.New().Uint32(1).String("a").Sum64(), hash(struct {
+			Tenant uint32
+			Path   string
+		}{1, "a"}))
+		_, err = defaultHashFunc[struct {
+			A uint8
+			B uint64
+		}]()
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		// 含指针、切片的类型以及浮点数需要指定HashFunc
+		_, err := defaultHashFunc[struct{ P *int }]()
+		assert.Equal(t, ErrHashFuncRequired, err)
+		_, err = defaultHashFunc[float64]()
+		assert.Equal(t, ErrHashFuncRequired, err)
+		_, err = defaultHashFunc[struct {
+			A uint8
+			S []byte
+		}]()
+		assert.Equal(t, ErrHashFuncRequired, err)
+		_, err = NewPriorityLRU[*int, []byte](10, 1, nil, nil)
+		assert.Equal(t, ErrHashFuncRequired, err)
+	})
+
+	t.Run("lru_without_hash_func", func(t *testing.T) {
+		// 不指定HashFunc时正常读写
+		lru, err := NewPriorityLRU[tenantKey, string](10, 1, nil, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, lru.Add(tenantKey{1, 2, 3}, "v", 0))
+		value, ok, err := lru.Get(tenantKey{1, 2, 3})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "v", value)
+
+		ints, err := NewPriorityLRU[uint64, string](10, 1, nil, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, ints.Add(42, "v", 0))
+		_, ok, _ = ints.Get(42)
+		assert.True(t, ok)
+	})
+}
+
+func TestHashFunc64(t *testing.T) {
+	t.Run("fingerprint", func(t *testing.T) {
+		// 同一个桶中哈希值不同的key先比较哈希值
+		lru, err := NewPriorityLRUWithOptions[uint64, string](10, 1, Options[uint64, string]{
+			HashFunc64: func(k uint64) uint64 {
+				return k * 10 << 32 // 对桶数取模相同，全部落在同一个桶
+			},
+		})
+		assert.NoError(t, err)
+		for i := uint64(1); i <= 5; i++ {
+			assert.NoError(t, lru.Add(i, fmt.Sprintf("val_%d", i), 0))
+		}
+		assert.Equal(t, uint64(4), lru.Metrics().Conflict)
+		for i := uint64(1); i <= 5; i++ {
+			value, ok, _ := lru.Get(i)
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("val_%d", i), value)
+		}
+		e := findEntry(lru, 3)
+		assert.Equal(t, uint64(30)<<32, e.HashId)
+	})
+
+	t.Run("hash32", func(t *testing.T) {
+		// 32位的HashFunc保存在低32位
+		lru, _ := NewPriorityLRU[string, []byte](10, 1, HashXXHASH, nil)
+		lru.Add("key1", nil, 0)
+		e := findEntry(lru, "key1")
+		assert.Equal(t, uint64(HashXXHASH("key1")), e.HashId)
+		assert.Equal(t, uint64(HashXXHASH("key1")), HashXXHASH64("key1")&0xffffffff)
+	})
+}
+
+// findEntry 不改变顺序地查找节点
+func findEntry[K comparable, V any](lru *LRU[K, V], key K) *jlist.Entry[K, V] {
+	hashId, bukPos := lru.hashToPos(key)
+	e, _, _ := lru.getEntryInBuk(bukPos, hashId, key)
+	return e
+}
@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Hasher hashes and compares keys which may not be comparable by ==, like
+// []byte or structs holding slices.
+type Hasher[K any] interface {
+	Hash(K) uint64
+	Equal(a, b K) bool
+}
+
+// KeyCloner may be implemented by a Hasher, the cache then stores a clone of
+// every new key, so the callers can reuse the memory of their keys.
+type KeyCloner[K any] interface {
+	Clone(K) K
+}
+
+// BytesHasher compares []byte keys by value, the cache keeps its own copies
+// of the keys.
+type BytesHasher struct{}
+
+func (BytesHasher) Hash(k []byte) uint64 {
+	return xxhash.Sum64(k)
+}
+
+func (BytesHasher) Equal(a, b []byte) bool {
+	return bytes.Equal(a, b)
+}
+
+func (BytesHasher) Clone(k []byte) []byte {
+	return append(make([]byte, 0, len(k)), k...)
+}
+
+// NewPriorityLRUWithHasher creates a cache whose keys are hashed and compared
+// by hasher, so K does not need to be comparable. opts must not set HashFunc,
+// HashFunc64 or SeededHash.
+func NewPriorityLRUWithHasher[K any, V any](capacity int, maxPriority byte, hasher Hasher[K], opts Options[K, V]) (*LRU[K, V], error) {
+	if hasher == nil {
+		return nil, errors.New("HasherRequired")
+	}
+	if opts.HashFunc != nil || opts.HashFunc64 != nil || opts.SeededHash {
+		return nil, errors.New("HashFuncWithHasher")
+	}
+	opts.HashFunc64 = hasher.Hash
+	lru, err := newLRU(capacity, maxPriority, opts, hasher.Equal)
+	if err != nil {
+		return nil, err
+	}
+	if cloner, ok := hasher.(KeyCloner[K]); ok {
+		lru.cloneKey = cloner.Clone
+	}
+	return lru, nil
+}
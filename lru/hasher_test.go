@@ -0,0 +1,84 @@
+package lru
+
+import (
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type objectKey struct {
+	Tenant uint32
+	Parts  []string
+}
+
+type objectKeyHasher struct{}
+
+func (objectKeyHasher) Hash(k objectKey) uint64 {
+	h := xxhash.New()
+	for _, p := range k.Parts {
+		_, _ = h.WriteString(p)
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64() ^ uint64(k.Tenant)
+}
+
+func (objectKeyHasher) Equal(a, b objectKey) bool {
+	if a.Tenant != b.Tenant || len(a.Parts) != len(b.Parts) {
+		return false
+	}
+	for i := range a.Parts {
+		if a.Parts[i] != b.Parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHasher(t *testing.T) {
+	t.Run("bytes_key", func(t *testing.T) {
+		// []byte作为key，按值比较，缓存持有key的副本
+		lru, err := NewPriorityLRUWithHasher[[]byte, string](10, 1, BytesHasher{}, Options[[]byte, string]{})
+		assert.NoError(t, err)
+		buf := []byte("key1")
+		assert.NoError(t, lru.Add(buf, "val1", 0))
+		copy(buf, "key2") // 调用方复用缓冲区
+		assert.NoError(t, lru.Add(buf, "val2", 0))
+		value, ok, err := lru.Get([]byte("key1"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		value, ok, _ = lru.Get([]byte("key2"))
+		assert.True(t, ok)
+		assert.Equal(t, "val2", value)
+		assert.Equal(t, uint32(2), lru.Len())
+
+		assert.NoError(t, lru.Add([]byte("key1"), "val3", 0)) // 更新已有key
+		value, _, _ = lru.Get([]byte("key1"))
+		assert.Equal(t, "val3", value)
+		_, ok, err = lru.Remove([]byte("key1"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(1), lru.Len())
+	})
+
+	t.Run("struct_with_slice", func(t *testing.T) {
+		// 含切片的结构体使用自定义Hasher
+		lru, err := NewPriorityLRUWithHasher[objectKey, int](2, 1, objectKeyHasher{}, Options[objectKey, int]{})
+		assert.NoError(t, err)
+		lru.Add(objectKey{1, []string{"a", "b"}}, 1, 0)
+		lru.Add(objectKey{2, []string{"a", "b"}}, 2, 0)
+		lru.Add(objectKey{1, []string{"c"}}, 3, 0) // 驱逐第一个key
+		_, ok, _ := lru.Get(objectKey{1, []string{"a", "b"}})
+		assert.False(t, ok)
+		value, ok, _ := lru.Get(objectKey{2, []string{"a", "b"}})
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewPriorityLRUWithHasher[[]byte, string](10, 1, nil, Options[[]byte, string]{})
+		assert.Error(t, err)
+		_, err = NewPriorityLRUWithHasher[[]byte, string](10, 1, BytesHasher{}, Options[[]byte, string]{SeededHash: true})
+		assert.Error(t, err)
+	})
+}
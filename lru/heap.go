@@ -0,0 +1,118 @@
+package lru
+
+// idxHeap is a binary min heap of arena indices. pos maps an index to its
+// place in the heap, so an entry can be fixed or removed in O(log n).
+type idxHeap struct {
+	items []uint32
+	pos   []uint32 // arena idx -> position in items, invalidIdx if absent
+	less  func(a, b uint32) bool
+}
+
+func newIdxHeap(less func(a, b uint32) bool) *idxHeap {
+	return &idxHeap{
+		pos:  []uint32{},
+		less: less,
+	}
+}
+
+// grow extends pos to n arena indices with the arena, see LRU.growSide.
+func (h *idxHeap) grow(n uint32, max uint32) {
+	old := len(h.pos)
+	h.pos = growSlice(h.pos, n, max)
+	for i := old; i < len(h.pos); i++ {
+		h.pos[i] = invalidIdx
+	}
+}
+
+// reset removes all the indices.
+func (h *idxHeap) reset() {
+	for _, idx := range h.items {
+		h.pos[idx] = invalidIdx
+	}
+	h.items = h.items[:0]
+}
+
+func (h *idxHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *idxHeap) contains(idx uint32) bool {
+	return h.pos[idx] != invalidIdx
+}
+
+// min returns the smallest index without removing it.
+func (h *idxHeap) min() (uint32, bool) {
+	if len(h.items) == 0 {
+		return invalidIdx, false
+	}
+	return h.items[0], true
+}
+
+// fix pushes idx, or restores its place after its key changed.
+func (h *idxHeap) fix(idx uint32) {
+	i := h.pos[idx]
+	if i == invalidIdx {
+		h.items = append(h.items, idx)
+		h.pos[idx] = uint32(len(h.items) - 1)
+		h.up(len(h.items) - 1)
+		return
+	}
+	if !h.down(int(i)) {
+		h.up(int(i))
+	}
+}
+
+func (h *idxHeap) remove(idx uint32) {
+	i := h.pos[idx]
+	if i == invalidIdx {
+		return
+	}
+	last := len(h.items) - 1
+	if int(i) != last {
+		h.swap(int(i), last)
+	}
+	h.items = h.items[:last]
+	h.pos[idx] = invalidIdx
+	if int(i) != last {
+		if !h.down(int(i)) {
+			h.up(int(i))
+		}
+	}
+}
+
+func (h *idxHeap) swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.pos[h.items[i]] = uint32(i)
+	h.pos[h.items[j]] = uint32(j)
+}
+
+func (h *idxHeap) up(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !h.less(h.items[j], h.items[i]) {
+			break
+		}
+		h.swap(i, j)
+		j = i
+	}
+}
+
+func (h *idxHeap) down(i0 int) bool {
+	i := i0
+	n := len(h.items)
+	for {
+		j := 2*i + 1
+		if j >= n {
+			break
+		}
+		if j2 := j + 1; j2 < n && h.less(h.items[j2], h.items[j]) {
+			j = j2
+		}
+		if !h.less(h.items[j], h.items[i]) {
+			break
+		}
+		h.swap(i, j)
+		i = j
+	}
+	return i > i0
+}
@@ -0,0 +1,69 @@
+package lru
+
+// Hooks observe the entries of the cache besides eviction, like for auditing or
+// for keeping a secondary index in sync. The hooks which are nil cost nothing.
+type Hooks[K any, V any] struct {
+	// OnInsert is called after Add or AddToBack stored a new key.
+	OnInsert func(key K, value V)
+	// OnUpdate is called after Add or AddToBack replaced the value of a key.
+	OnUpdate func(key K, old V, new V)
+	// OnHit is called after Get found key.
+	OnHit func(key K, value V)
+	// OnMiss is called after Get did not find key.
+	OnMiss func(key K)
+	// InLock runs the hooks before the lock is released, so they see the
+	// changes in order. They must not use the cache then. By default they run
+	// after the lock is released.
+	InLock bool
+}
+
+func (h *Hooks[K, V]) empty() bool {
+	return h.OnInsert == nil && h.OnUpdate == nil && h.OnHit == nil && h.OnMiss == nil
+}
+
+type hookOp byte
+
+const (
+	hookNone hookOp = iota
+	hookInsert
+	hookUpdate
+)
+
+// hookCall holds the hook of a write until it is run.
+type hookCall[K any, V any] struct {
+	op    hookOp
+	key   K
+	old   V
+	value V
+}
+
+func (lru *LRU[K, V]) inserted(h *hookCall[K, V], key K, value V) {
+	if lru.hooks != nil && lru.hooks.OnInsert != nil {
+		*h = hookCall[K, V]{op: hookInsert, key: key, value: value}
+	}
+}
+
+func (lru *LRU[K, V]) updated(h *hookCall[K, V], key K, old V, value V) {
+	if lru.hooks != nil && lru.hooks.OnUpdate != nil {
+		*h = hookCall[K, V]{op: hookUpdate, key: key, old: old, value: value}
+	}
+}
+
+func (lru *LRU[K, V]) runHook(h *hookCall[K, V]) {
+	switch h.op {
+	case hookInsert:
+		lru.hooks.OnInsert(h.key, h.value)
+	case hookUpdate:
+		lru.hooks.OnUpdate(h.key, h.old, h.value)
+	}
+}
+
+// lookedUp runs the hook of a Get.
+func (lru *LRU[K, V]) lookedUp(key K, value V, ok bool, err error) {
+	switch {
+	case ok && lru.hooks.OnHit != nil:
+		lru.hooks.OnHit(key, value)
+	case !ok && err == nil && lru.hooks.OnMiss != nil:
+		lru.hooks.OnMiss(key)
+	}
+}
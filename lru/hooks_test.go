@@ -0,0 +1,91 @@
+package lru
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type hookLog struct {
+	ops []string
+}
+
+func (h *hookLog) hooks(lru **LRU[string, string], inLock bool) Hooks[string, string] {
+	return Hooks[string, string]{
+		OnInsert: func(key string, value string) {
+			h.ops = append(h.ops, "insert "+key+"="+value)
+			if !inLock {
+				(*lru).Len() // 锁已释放
+			}
+		},
+		OnUpdate: func(key string, old string, new string) {
+			h.ops = append(h.ops, "update "+key+"="+old+"->"+new)
+		},
+		OnHit: func(key string, value string) {
+			h.ops = append(h.ops, "hit "+key+"="+value)
+			if !inLock {
+				(*lru).Len()
+			}
+		},
+		OnMiss: func(key string) {
+			h.ops = append(h.ops, "miss "+key)
+		},
+		InLock: inLock,
+	}
+}
+
+func TestHooks(t *testing.T) {
+	for _, policy := range []Policy{PolicyLRU, PolicySIEVE} {
+		for _, inLock := range []bool{false, true} {
+			// 写入、更新、命中、未命中各触发一次对应的hook
+			var lru *LRU[string, string]
+			log := &hookLog{}
+			lru, _ = NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{
+				Policy: policy,
+				Hooks:  log.hooks(&lru, inLock),
+			})
+			lru.Add("key1", "val1", 0)
+			lru.Add("key1", "val2", 0)
+			lru.AddToBack("key2", "val3", 0)
+			lru.AddToBack("key2", "val4", 0)
+			lru.Get("key1")
+			lru.Get("key3")
+			lru.Remove("key1")
+			assert.Equal(t, []string{
+				"insert key1=val1",
+				"update key1=val1->val2",
+				"insert key2=val3",
+				"update key2=val3->val4",
+				"hit key1=val2",
+				"miss key3",
+			}, log.ops)
+		}
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		// 未写入或缓存关闭时不触发hook
+		log := &hookLog{}
+		var lru *LRU[string, string]
+		lru, _ = NewPriorityLRUWithOptions[string, string](1, 1, Options[string, string]{
+			Hooks: log.hooks(&lru, false),
+		})
+		lru.Close()
+		lru.Add("key1", "val1", 0)
+		lru.Get("key1")
+		assert.Empty(t, log.ops)
+	})
+
+	t.Run("no_alloc", func(t *testing.T) {
+		// 未设置与设置hook时Add和Get都不分配内存
+		var hits int
+		for _, hooks := range []Hooks[int, int]{{}, {OnHit: func(key int, value int) { hits++ }}} {
+			lru, _ := NewPriorityLRUWithOptions[int, int](10, 1, Options[int, int]{Hooks: hooks})
+			lru.Add(1, 1, 0)
+			allocs := testing.AllocsPerRun(100, func() {
+				lru.Add(1, 1, 0)
+				lru.Get(1)
+			})
+			assert.Equal(t, float64(0), allocs)
+		}
+		assert.True(t, hits > 0)
+	})
+}
@@ -0,0 +1,155 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+	"math"
+)
+
+// lfu keeps every priority band sorted by frequency, the most frequent entries
+// at the front. Entries of the same frequency form a bucket ordered by recency,
+// and heads remembers the most recent entry of every bucket, so a hit moves an
+// entry to the front of the next bucket in O(1). The least frequently used
+// entry of a band is therefore at its back, like the oldest entry of the lru.
+// After decayWindow hits all the counts are halved, which keeps the order.
+type lfuState struct {
+	heads       map[uint64]uint32 // priority<<32|freq -> idx of the bucket head
+	hits        uint32
+	decayWindow uint32
+}
+
+func newLfuState(capacity int) *lfuState {
+	return &lfuState{
+		heads:       make(map[uint64]uint32),
+		decayWindow: uint32(10 * capacity),
+	}
+}
+
+func (lfu *lfuState) reset() {
+	for k := range lfu.heads {
+		delete(lfu.heads, k)
+	}
+	lfu.hits = 0
+}
+
+func lfuBucket(priority byte, freq uint32) uint64 {
+	return uint64(priority)<<32 | uint64(freq)
+}
+
+// lfuFrontMarkNode returns the node before which a new entry is the most
+// recent one of frequency 1.
+func (lru *LRU[K, V]) lfuFrontMarkNode(priority byte) (*jlist.Entry[K, V], error) {
+	if idx, ok := lru.lfu.heads[lfuBucket(priority, 1)]; ok {
+		return lru.ll.Entry(idx)
+	}
+	return lru.getPriorityMarkNode(priority)
+}
+
+// lfuLink counts a new entry in the bucket of frequency 1.
+func (lru *LRU[K, V]) lfuLink(e *jlist.Entry[K, V], toBack bool) {
+	lru.freqs[e.Idx()] = 1
+	bucket := lfuBucket(e.Priority, 1)
+	if _, ok := lru.lfu.heads[bucket]; !ok || !toBack {
+		lru.lfu.heads[bucket] = e.Idx()
+	}
+}
+
+// lfuUnlink takes e out of its bucket, the list is not changed.
+func (lru *LRU[K, V]) lfuUnlink(e *jlist.Entry[K, V]) {
+	freq := lru.freqs[e.Idx()]
+	bucket := lfuBucket(e.Priority, freq)
+	if idx, ok := lru.lfu.heads[bucket]; !ok || idx != e.Idx() {
+		return
+	}
+	next, err := lru.ll.Entry(e.Next())
+	if err == nil && next.Flag == 0 && next.Priority == e.Priority && lru.freqs[next.Idx()] == freq {
+		lru.lfu.heads[bucket] = next.Idx()
+		return
+	}
+	delete(lru.lfu.heads, bucket)
+}
+
+// lfuHit moves e to the front of the bucket of its next frequency.
+func (lru *LRU[K, V]) lfuHit(e *jlist.Entry[K, V]) error {
+	lru.lfuUnlink(e)
+	last := lru.freqs[e.Idx()]
+	freq := last
+	if freq < math.MaxUint32 {
+		freq++
+	}
+	idx, ok := lru.lfu.heads[lfuBucket(e.Priority, freq)]
+	if !ok {
+		idx, ok = lru.lfu.heads[lfuBucket(e.Priority, last)]
+	}
+	if ok {
+		markNode, err := lru.ll.Entry(idx)
+		if err != nil {
+			return err
+		}
+		err = lru.ll.MoveBefore(e, markNode)
+		if err != nil {
+			return err
+		}
+	}
+	lru.freqs[e.Idx()] = freq
+	lru.lfu.heads[lfuBucket(e.Priority, freq)] = e.Idx()
+	lru.lfu.hits++
+	if lru.lfu.hits >= lru.lfu.decayWindow {
+		return lru.lfuDecay()
+	}
+	return nil
+}
+
+// lfuMove moves e to the front of its bucket in band priority.
+func (lru *LRU[K, V]) lfuMove(e *jlist.Entry[K, V], priority byte) {
+	lru.lfuUnlink(e)
+	e.Priority = priority
+	freq := lru.freqs[e.Idx()]
+	node, err := lru.getPriorityMarkNode(priority + 1)
+	if err != nil {
+		return
+	}
+	for {
+		node, err = lru.ll.Entry(node.Next())
+		if err != nil {
+			return
+		}
+		if node.Flag != 0 || lru.freqs[node.Idx()] <= freq {
+			break
+		}
+	}
+	if lru.ll.MoveBefore(e, node) != nil {
+		return
+	}
+	lru.lfu.heads[lfuBucket(priority, freq)] = e.Idx()
+}
+
+// lfuDecay halves the counts of all the entries and rebuilds the bucket heads.
+func (lru *LRU[K, V]) lfuDecay() error {
+	heads := make(map[uint64]uint32, len(lru.lfu.heads))
+	for i := 0; i <= int(lru.maxPriority); i++ {
+		node, err := lru.getPriorityMarkNode(byte(i + 1))
+		if err != nil {
+			return err
+		}
+		for {
+			node, err = lru.ll.Entry(node.Next())
+			if err != nil {
+				return err
+			}
+			if node.Flag != 0 {
+				break
+			}
+			freq := &lru.freqs[node.Idx()]
+			if *freq > 1 {
+				*freq /= 2
+			}
+			bucket := lfuBucket(node.Priority, *freq)
+			if _, ok := heads[bucket]; !ok {
+				heads[bucket] = node.Idx()
+			}
+		}
+	}
+	lru.lfu.heads = heads
+	lru.lfu.hits = 0
+	return nil
+}
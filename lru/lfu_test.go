@@ -0,0 +1,133 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// checkLfuOrder 检查每个优先级内频率从前到后递减，并且bucket头节点正确
+func checkLfuOrder(t *testing.T, lru *LRU[string, []byte]) {
+	heads := 0
+	for i := 0; i <= int(lru.maxPriority); i++ {
+		node, _ := lru.getPriorityMarkNode(byte(i + 1))
+		var prev uint32
+		for {
+			node, _ = lru.ll.Entry(node.Next())
+			if node.Flag != 0 {
+				break
+			}
+			assert.Equal(t, byte(i), node.Priority)
+			freq := lru.freqs[node.Idx()]
+			if prev != freq {
+				assert.True(t, prev == 0 || prev > freq, "freq order")
+				assert.Equal(t, node.Idx(), lru.lfu.heads[lfuBucket(node.Priority, freq)], "bucket head")
+				heads++
+				prev = freq
+			}
+		}
+	}
+	assert.Equal(t, heads, len(lru.lfu.heads))
+}
+
+func TestLFU(t *testing.T) {
+	t.Run("frequency", func(t *testing.T) {
+		lru := newPolicyLRU(3, 1, PolicyLFU)
+		lru.Add("key1", []byte("val1"), 0)
+		_, freq, ok, err := lru.GetWithFrequency("key1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(2), freq)
+		_, freq, _, _ = lru.GetWithFrequency("key1")
+		assert.Equal(t, uint32(3), freq)
+		_, freq, ok, _ = lru.GetWithFrequency("key2")
+		assert.False(t, ok)
+		assert.Equal(t, uint32(0), freq)
+		checkLfuOrder(t, lru)
+	})
+
+	t.Run("evict_least_frequent", func(t *testing.T) {
+		// 驱逐访问频率最低的节点，频率相同时驱逐最久未访问的
+		lru := newPolicyLRU(3, 1, PolicyLFU)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Get("key1")
+		lru.Get("key1")
+		lru.Get("key2")
+		lru.Add("key4", []byte("val4"), 0) // 驱逐key3
+		_, ok, _ := lru.Get("key3")
+		assert.False(t, ok)
+		lru.Add("key5", []byte("val5"), 0) // key4的频率为1，被驱逐
+		_, ok, _ = lru.Get("key4")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("key2")
+		assert.True(t, ok)
+		checkLfuOrder(t, lru)
+	})
+
+	t.Run("priority_first", func(t *testing.T) {
+		// 优先级是第一淘汰依据，频率是第二依据
+		lru := newPolicyLRU(2, 2, PolicyLFU)
+		lru.Add("low", []byte("l"), 0)
+		for i := 0; i < 5; i++ {
+			lru.Get("low")
+		}
+		lru.Add("high", []byte("h"), 1)
+		lru.Add("new", []byte("n"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("high")
+		assert.True(t, ok)
+		checkLfuOrder(t, lru)
+	})
+
+	t.Run("decay", func(t *testing.T) {
+		// 访问次数达到窗口后频率减半
+		lru := newPolicyLRU(2, 1, PolicyLFU)
+		lru.lfu.decayWindow = 8
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		for i := 0; i < 6; i++ {
+			lru.Get("key1")
+		}
+		lru.Get("key2")
+		_, freq, _, _ := lru.GetWithFrequency("key2") // 第8次访问触发衰减
+		assert.Equal(t, uint32(1), freq)
+		lru.Sync()
+		_, freq, _, _ = lru.GetWithFrequency("key1")
+		assert.Equal(t, uint32(4), freq)
+		checkLfuOrder(t, lru)
+	})
+
+	t.Run("random_operations", func(t *testing.T) {
+		lru := newPolicyLRU(20, 3, PolicyLFU)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("key%d", r.Intn(40))
+			switch r.Intn(5) {
+			case 0:
+				lru.Add(key, []byte(key), byte(r.Intn(4)))
+			case 1:
+				lru.AddToBack(key, []byte(key), byte(r.Intn(4)))
+			case 2:
+				lru.Remove(key)
+			default:
+				lru.Get(key)
+			}
+		}
+		assert.Equal(t, uint64(0), lru.Metrics().Errors)
+		checkLfuOrder(t, lru)
+	})
+}
+
+func TestLFU_HitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 5000, 1000, 500)
+	lruRatio := hitRatio(newPolicyLRU(500, 1, PolicyLRU), trace)
+	lfuRatio := hitRatio(newPolicyLRU(500, 1, PolicyLFU), trace)
+	t.Logf("lru: %.4f lfu: %.4f", lruRatio, lfuRatio)
+	assert.True(t, lfuRatio >= lruRatio)
+}
@@ -1,6 +1,7 @@
 package lru
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/cespare/xxhash/v2"
@@ -8,12 +9,24 @@ import (
 	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const emptyBucket = math.MaxUint32
 const invalidIdx = math.MaxUint32
 const maxEntryPriority = 100
 
+// ErrRejected is returned by Add when the admission filter keeps a new entry out of a full cache.
+var ErrRejected = errors.New("AdmissionRejected")
+
+// ErrClosed is returned by the calls on a cache after Close.
+var ErrClosed = errors.New("Closed")
+
+// ErrSavedNotCached is returned by Add in write-through mode when the value was
+// saved to the Store but the cache did not take it. It wraps the cause, like
+// ErrRejected or ErrClosed, test it with errors.Is.
+var ErrSavedNotCached = errors.New("SavedNotCached")
+
 type ListMetrics struct {
 	Inserts   uint64
 	Evictions uint64
@@ -22,23 +35,155 @@ type ListMetrics struct {
 	Misses    uint64
 	Conflict  uint64
 	Errors    uint64
+	// DroppedPromotions counts the hits lost because a read buffer was full.
+	DroppedPromotions uint64
+	// Rejections counts the new entries refused by the admission filter.
+	Rejections uint64
+	// Reseeds counts the seed changes of Options.SeededHash.
+	Reseeds uint64
+	// SaveErrors counts the failed saves to Options.Store.
+	SaveErrors uint64
+	// DroppedEvictions counts the evicted entries not delivered because the
+	// queue of DeliverAsync was full.
+	DroppedEvictions uint64
 }
 
 func HashXXHASH(s string) uint32 {
 	return uint32(xxhash.Sum64String(s))
 }
 
+// HashXXHASH64 is HashXXHASH with all the 64 bits.
+func HashXXHASH64(s string) uint64 {
+	return xxhash.Sum64String(s)
+}
+
 // HashKeyCallback is the function that creates a hash from the passed key.
-type HashKeyCallback[K comparable] func(K) uint32
+type HashKeyCallback[K any] func(K) uint32
+
+// HashKeyCallback64 is HashKeyCallback with a 64 bit hash. The whole hash is
+// kept in the entry and compared before the key.
+type HashKeyCallback64[K any] func(K) uint64
 
-type OnEvictCallback[K comparable, V any] func(K, V) bool
+// OnEvictCallback is called with an entry the cache evicts. Returning false
+// keeps the entry, but only with DeliverInLock, the other deliveries run after
+// the entry is gone and ignore the result.
+type OnEvictCallback[K any, V any] func(K, V) bool
+
+// EvictReason tells OnEvictedReason why an entry left the cache.
+type EvictReason byte
+
+const (
+	// EvictCapacity is an entry evicted to make room, or by RemoveOldest.
+	EvictCapacity EvictReason = iota
+	// EvictCleared is an entry dropped by Purge or Clear.
+	EvictCleared
+)
+
+// OnEvictReasonCallback is called with every entry evicted or purged from the
+// cache, it cannot keep the entry.
+type OnEvictReasonCallback[K any, V any] func(K, V, EvictReason)
+
+// Policy selects how entries are ordered inside a priority band.
+type Policy byte
+
+const (
+	// PolicyLRU is the strict lru, Get moves the entry to the front of its band.
+	PolicyLRU Policy = iota
+	// PolicyClock approximates lru with a reference bit, so Get only needs the read lock.
+	PolicyClock
+	// PolicyARC is the adaptive replacement cache, it balances recency and frequency.
+	PolicyARC
+	// PolicySLRU splits every band into a probation and a protected segment.
+	PolicySLRU
+	// PolicyLFU evicts the least frequently used entry of the lowest band, the
+	// counts are halved periodically.
+	PolicyLFU
+	// PolicyGDSF is greedy dual size frequency, it evicts the entry with the
+	// lowest priority and hits per size first, see Options.SizeFunc.
+	PolicyGDSF
+	// PolicyLRU2 evicts by the time of the second most recent access, see
+	// Options.HistoryRatio.
+	PolicyLRU2
+	// PolicySIEVE sets a visited bit on hit under the read lock and never
+	// reorders, a hand sweeps every band from the back on eviction.
+	PolicySIEVE
+)
+
+// segments of a priority band, used by the segmented policies.
+const (
+	segRecent   byte = 0 // back of the band, t1 of arc, probation of slru
+	segFrequent byte = 1 // front of the band, t2 of arc, protected of slru
+)
+
+// Options holds the settings of NewPriorityLRUWithOptions.
+type Options[K any, V any] struct {
+	// HashFunc hashes the keys. If nil a default hasher is picked for integers,
+	// strings, byte arrays and structs of them, see NewMaphashHasher and
+	// hashbuilder.StructHasher.
+	HashFunc HashKeyCallback[K]
+	// HashFunc64 is used instead of HashFunc if set.
+	HashFunc64 HashKeyCallback64[K]
+	// OnEvicted is called with the evicted entries, see OnEvictCallback for
+	// when its result is honored.
+	OnEvicted OnEvictCallback[K, V]
+	// OnEvictedReason is called after OnEvicted with the entries which left
+	// the cache, and with the entries dropped by Purge.
+	OnEvictedReason OnEvictReasonCallback[K, V]
+	// EvictDelivery selects when the eviction callbacks run, after the lock
+	// is released by default.
+	EvictDelivery EvictDelivery
+	// EvictQueueSize bounds the queue of DeliverAsync, 1024 by default.
+	EvictQueueSize int
+	// Backpressure is what DeliverAsync does when its queue is full.
+	Backpressure Backpressure
+	// Hooks observe the inserts, updates, hits and misses.
+	Hooks Hooks[K, V]
+	// Store is the storage behind the cache, GetOrLoad loads the misses from it.
+	Store Store[K, V]
+	// WriteBack marks the entries written by Add and AddToBack dirty, they are
+	// saved to Store when evicted or by Flush. A failed save pins the entry in
+	// the highest band until a retry succeeds, Remove deletes from Store.
+	WriteBack bool
+	// WriteThrough saves the writes of Add and AddToBack to Store before the
+	// cache sees them, and Remove deletes from Store first. The writers of a
+	// key are serialized so the cache and the Store agree on its last value.
+	WriteThrough bool
+	// RetryBackoff is the delay before retrying a failed save, doubled on every
+	// failure up to MaxRetryBackoff. 100ms and 30s by default.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	Policy          Policy
+	// ReadBuffer records the hits of Get in lossy buffers and applies them to
+	// the lru order in batches, so Get only needs the read lock.
+	// Not supported by PolicyClock and PolicySIEVE.
+	ReadBuffer bool
+	// TinyLFU puts an admission filter in front of a full cache, a new entry
+	// only replaces the victim if it was seen more often recently.
+	TinyLFU bool
+	// ProtectedRatio is the share of a priority band kept in the protected
+	// segment of PolicySLRU, 0.8 by default.
+	ProtectedRatio float64
+	// SizeFunc returns the size of an entry, PolicyGDSF takes it as the cost of
+	// the entry and MemoryUsage as its payload. If nil the Sizer of the key and
+	// the value is used, every entry has size 1 for PolicyGDSF without both.
+	SizeFunc func(K, V) uint64
+	// HistoryRatio sizes the history of evicted keys of PolicyLRU2 as a share
+	// of the capacity, 0.5 by default.
+	HistoryRatio float64
+	// SeededHash hashes the keys with a random seed per cache, which is changed
+	// when the keys build too long chains. HashFunc must be nil, the key must be
+	// a string or a pointer-free type.
+	SeededHash bool
+}
 
 // LRU  a lru supports priority.
-type LRU[K comparable, V any] struct {
+type LRU[K any, V any] struct {
 	metrics ListMetrics
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	OnEvicted OnEvictCallback[K, V]
+	// OnEvictedReason is called with the entries evicted or purged.
+	OnEvictedReason OnEvictReasonCallback[K, V]
 
 	ll          *jlist.List[K, V]
 	buckets     []uint32
@@ -46,44 +191,269 @@ type LRU[K comparable, V any] struct {
 	pos         []uint32
 	maxPriority byte
 	sync.RWMutex
-	hashFunc HashKeyCallback[K]
+	hashFunc HashKeyCallback64[K]
+	equal    func(a, b K) bool
+	cloneKey func(K) K // copies the keys owned by the cache, nil if they are not copied
+	policy   Policy
+	readBufs []readBuffer
+	markers  uint32
+	seg      []uint32    // segment mark nodes, nil for the unsegmented policies
+	segs     []byte      // arena idx -> segment, nil for the unsegmented policies
+	segLen   [2]uint32   // entries per segment
+	bandSeg  [][2]uint32 // entries per segment of every band
+	arc      *arcState
+	slru     *slruState
+	lfu      *lfuState
+	gdsf     *gdsfState
+	lruK     *lruKState
+	sieve    *sieveState
+	refs     []uint32 // arena idx -> reference bit, nil unless PolicyClock or PolicySIEVE
+	freqs    []uint32 // arena idx -> access frequency, nil unless PolicyLFU or PolicyGDSF
+	seeded   *seededHash[K]
+	sizeFunc func(*K, *V) uint64
+	sizes    []uint64 // arena idx -> size counted in payload, nil without sizeFunc
+	side     uint32   // arena blocks covered by the slices above, see growSide
+	payload  uint64   // bytes reported by sizeFunc for the entries
+	sketch   *tinyLFU
+	closed   bool
+
+	delivery     EvictDelivery
+	pending      []evictedEntry[K, V] // evicted under the lock, delivered by unlock
+	saves        []saveJob[K, V]      // queued under the lock, run by unlock
+	evictQueue   *evictQueue[K, V]
+	hooks        *Hooks[K, V] // nil if no hook is set
+	store        Store[K, V]
+	writeBack    *writeBack // nil unless Options.WriteBack
+	writeThrough bool
+	keyLocks     *keyLocks[K] // nil unless Options.WriteBack or Options.WriteThrough
+	keyLock      *sync.Mutex  // key lock of the writer, released by unlock before the callbacks
+	subs         []*subscriber[K, V]
+	seq          uint64 // seq of the last published event
+	watches      map[uint64][]*watcher[K, V]
 }
 
+// NewPriorityLRU delivers onEvicted with DeliverInLock, so it may still keep
+// an entry by returning false, and must not use the cache.
 func NewPriorityLRU[K comparable, V any](capacity int, maxPriority byte, hashFunc HashKeyCallback[K], onEvicted OnEvictCallback[K, V]) (*LRU[K, V], error) {
+	return NewPriorityLRUWithOptions[K, V](capacity, maxPriority, Options[K, V]{
+		HashFunc:      hashFunc,
+		OnEvicted:     onEvicted,
+		EvictDelivery: DeliverInLock,
+	})
+}
+
+func NewPriorityLRUWithOptions[K comparable, V any](capacity int, maxPriority byte, opts Options[K, V]) (*LRU[K, V], error) {
+	return newLRU(capacity, maxPriority, opts, func(a, b K) bool {
+		return a == b
+	})
+}
+
+func newLRU[K any, V any](capacity int, maxPriority byte, opts Options[K, V], equal func(a, b K) bool) (*LRU[K, V], error) {
 	if capacity == 0 {
 		return nil, errors.New("CapacityTooSmall")
 	}
 	if maxPriority > maxEntryPriority {
 		maxPriority = maxEntryPriority
 	}
+	switch opts.Policy {
+	case PolicyLRU, PolicyClock, PolicyARC, PolicySLRU, PolicyLFU, PolicyGDSF, PolicyLRU2, PolicySIEVE:
+	default:
+		return nil, errors.New("UnknownPolicy")
+	}
+	if opts.EvictDelivery > DeliverAsync || opts.Backpressure > BackpressureCallerRuns {
+		return nil, errors.New("UnknownEvictDelivery")
+	}
+	if (opts.WriteBack || opts.WriteThrough) && opts.Store == nil {
+		return nil, ErrStoreRequired
+	}
+	if opts.WriteBack && opts.WriteThrough {
+		return nil, errors.New("WriteModeConflict")
+	}
+	if opts.ReadBuffer && (opts.Policy == PolicyClock || opts.Policy == PolicySIEVE) {
+		return nil, errors.New("ReadBufferUnsupported")
+	}
+	var seeded *seededHash[K]
+	if opts.SeededHash {
+		if opts.HashFunc != nil || opts.HashFunc64 != nil {
+			return nil, errors.New("SeededHashUnsupported")
+		}
+		var err error
+		seeded, err = newSeededHash[K]()
+		if err != nil {
+			return nil, err
+		}
+		opts.HashFunc64 = func(k K) uint64 {
+			return seeded.hash(seeded.seed, k)
+		}
+	}
+	if opts.HashFunc64 == nil && opts.HashFunc != nil {
+		hashFunc := opts.HashFunc
+		opts.HashFunc64 = func(k K) uint64 {
+			return uint64(hashFunc(k))
+		}
+	}
+	if opts.HashFunc64 == nil {
+		hashFunc, err := defaultHashFunc[K]()
+		if err != nil {
+			return nil, err
+		}
+		opts.HashFunc64 = hashFunc
+	}
 	lru := &LRU[K, V]{
-		OnEvicted:   onEvicted,
-		cap:         uint32(capacity),
-		buckets:     make([]uint32, capacity),
-		pos:         make([]uint32, maxPriority+2),
-		maxPriority: maxPriority,
-		hashFunc:    hashFunc,
-	}
-	lru.ll = jlist.NewList[K, V](capacity + int(maxPriority) + 2)
+		OnEvicted:       opts.OnEvicted,
+		OnEvictedReason: opts.OnEvictedReason,
+		cap:             uint32(capacity),
+		buckets:         make([]uint32, capacity),
+		pos:             make([]uint32, maxPriority+2),
+		maxPriority:     maxPriority,
+		hashFunc:        opts.HashFunc64,
+		equal:           equal,
+		policy:          opts.Policy,
+		seeded:          seeded,
+		delivery:        opts.EvictDelivery,
+	}
+	if opts.SizeFunc != nil {
+		sizeFunc := opts.SizeFunc
+		lru.sizeFunc = func(k *K, v *V) uint64 {
+			return sizeFunc(*k, *v)
+		}
+	} else {
+		lru.sizeFunc = sizerFunc[K, V]()
+	}
+	if opts.ReadBuffer {
+		lru.readBufs = make([]readBuffer, readBufferStripes)
+	}
+	if opts.TinyLFU {
+		lru.sketch = newTinyLFU(capacity)
+	}
+	lru.markers = uint32(maxPriority) + 2
+	switch opts.Policy {
+	case PolicyARC:
+		lru.arc = newArcState(capacity)
+	case PolicySLRU:
+		lru.slru = newSlruState(opts.ProtectedRatio)
+	case PolicyLFU:
+		lru.lfu = newLfuState(capacity)
+	case PolicySIEVE:
+		lru.sieve = newSieveState(maxPriority)
+	}
+	if lru.arc != nil || lru.slru != nil {
+		lru.seg = make([]uint32, maxPriority+1)
+		lru.bandSeg = make([][2]uint32, maxPriority+1)
+		lru.markers += uint32(maxPriority) + 1
+	}
+	lru.ll = jlist.NewListFunc[K, V](capacity+int(lru.markers), equal)
+	if lru.seg != nil {
+		lru.segs = []byte{}
+	}
+	if lru.sizeFunc != nil {
+		lru.sizes = []uint64{}
+	}
+	switch opts.Policy {
+	case PolicyClock, PolicySIEVE:
+		lru.refs = []uint32{}
+	case PolicyLFU:
+		lru.freqs = []uint32{}
+	case PolicyGDSF:
+		lru.freqs = []uint32{}
+		lru.gdsf = newGdsfState()
+	case PolicyLRU2:
+		lru.lruK = newLruKState(lru.ll, capacity, opts.HistoryRatio)
+	}
+	if err := lru.initMarkers(); err != nil {
+		return nil, err
+	}
+	lru.growSide()
+	lru.store = opts.Store
+	if opts.WriteBack {
+		lru.writeBack = newWriteBack(opts.RetryBackoff, opts.MaxRetryBackoff)
+		lru.writeBack.settled = sync.NewCond(&lru.RWMutex)
+	}
+	lru.writeThrough = opts.WriteThrough
+	if opts.WriteBack || opts.WriteThrough {
+		lru.keyLocks = newKeyLocks(lru.hashFunc, seeded)
+	}
+	if !opts.Hooks.empty() {
+		hooks := opts.Hooks
+		lru.hooks = &hooks
+	}
+	if opts.EvictDelivery == DeliverAsync && (opts.OnEvicted != nil || opts.OnEvictedReason != nil) {
+		lru.evictQueue = newEvictQueue[K, V](opts.EvictQueueSize, opts.Backpressure, &lru.metrics.DroppedEvictions, lru.deliver)
+	}
+	return lru, nil
+}
+
+// growSide extends the slices kept beside the arena to the chunks it has
+// allocated, so a block costs their memory only once its chunk is in use.
+func (lru *LRU[K, V]) growSide() {
+	n, max := lru.ll.Allocated(), lru.ll.Cap()
+	lru.segs = growSlice(lru.segs, n, max)
+	lru.sizes = growSlice(lru.sizes, n, max)
+	lru.refs = growSlice(lru.refs, n, max)
+	lru.freqs = growSlice(lru.freqs, n, max)
+	if lru.gdsf != nil {
+		lru.gdsf.grow(n, max)
+	}
+	if lru.lruK != nil {
+		lru.lruK.grow(n, max)
+	}
+	lru.side = n
+}
+
+// growSlice extends s to n values, its capacity doubles up to max. A nil s is
+// left nil.
+func growSlice[T any](s []T, n uint32, max uint32) []T {
+	if s == nil || uint32(len(s)) >= n {
+		return s
+	}
+	if uint32(cap(s)) >= n {
+		return s[:n]
+	}
+	c := 2 * uint32(cap(s))
+	if c > max {
+		c = max
+	}
+	if c < n {
+		c = n
+	}
+	grown := make([]T, n, c)
+	copy(grown, s)
+	return grown
+}
+
+// initMarkers pushes the mark nodes into the empty list and empties the buckets.
+func (lru *LRU[K, V]) initMarkers() error {
 	for pos := range lru.pos {
 		e, err := lru.ll.PushFront(*new(K), *new(V), byte(pos))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		e.Flag = 1
 		lru.pos[pos] = e.Idx()
 	}
+	for pos := range lru.seg {
+		markNode, err := lru.getPriorityMarkNode(byte(pos))
+		if err != nil {
+			return err
+		}
+		e, err := lru.ll.InsertBefore(*new(K), *new(V), markNode)
+		if err != nil {
+			return err
+		}
+		e.Flag = 1
+		lru.seg[pos] = e.Idx()
+	}
 	for k := range lru.buckets {
 		lru.buckets[k] = emptyBucket
 	}
-	return lru, nil
+	return nil
 }
 
-func (lru *LRU[K, V]) getBucketPos(hashId uint32) uint32 {
-	return hashId % uint32(len(lru.buckets))
+func (lru *LRU[K, V]) getBucketPos(hashId uint64) uint32 {
+	return uint32(hashId % uint64(lru.cap))
 }
 
-func (lru *LRU[K, V]) getEntryInBuk(pos uint32, key K) (*jlist.Entry[K, V], bool, error) {
+func (lru *LRU[K, V]) getEntryInBuk(pos uint32, hashId uint64, key K) (*jlist.Entry[K, V], bool, error) {
 	if pos >= lru.cap {
 		return nil, false, errors.New("getEntryInBuk err: InvalidPos")
 	}
@@ -108,32 +478,33 @@ func (lru *LRU[K, V]) getEntryInBuk(pos uint32, key K) (*jlist.Entry[K, V], bool
 		return nil, false, nil
 	}
 	idx := startIdx
+	var hops int
 	for idx != emptyBucket {
 		e, err := lru.ll.Entry(idx)
 		if err != nil {
 			atomic.AddUint64(&lru.metrics.Errors, 1)
 			return nil, false, fmt.Errorf("getEntryInBuk err: %s", err.Error())
 		}
-		if e.Key == key {
+		if e.HashId == hashId && lru.equal(e.Key, key) {
 			return e, true, nil
 		}
 		idx = e.ConflictNext
 		if idx == startIdx {
 			break
 		}
+		hops++
+		if hops == maxChainLen && lru.seeded != nil {
+			lru.flooded()
+		}
 	}
 	return nil, false, nil
 }
 
-func (lru *LRU[K, V]) addEntryInBuk(pos uint32, newIdx uint32) error {
+func (lru *LRU[K, V]) addEntryInBuk(pos uint32, newEntry *jlist.Entry[K, V]) error {
 	if pos >= lru.cap {
 		return errors.New("addEntryInBuk err: InvalidPos")
 	}
-	newEntry, err := lru.ll.Entry(newIdx)
-	if err != nil {
-		atomic.AddUint64(&lru.metrics.Errors, 1)
-		return fmt.Errorf("addEntryInBuk err: %s", err.Error())
-	}
+	newIdx := newEntry.Idx()
 	startIdx := lru.buckets[pos]
 	if startIdx == emptyBucket {
 		lru.buckets[pos] = newIdx
@@ -178,23 +549,23 @@ func (lru *LRU[K, V]) addEntryInBuk(pos uint32, newIdx uint32) error {
 	return nil
 }
 
-func (lru *LRU[K, V]) removeEntryFromBuk(pos uint32, delIdx uint32) error {
+func (lru *LRU[K, V]) removeEntryFromBuk(pos uint32, delEntry *jlist.Entry[K, V]) error {
 	if pos >= lru.cap {
 		return errors.New("removeEntryFromBuk err: invalidPos")
 	}
-	delEntry, err := lru.ll.Entry(delIdx)
-	if err != nil {
-		atomic.AddUint64(&lru.metrics.Errors, 1)
-		return fmt.Errorf("removeEntryFromBuk err: %s", err.Error())
-	}
+	delIdx := delEntry.Idx()
 	startIdx := lru.buckets[pos]
 	if startIdx == invalidIdx {
 		return nil
 	}
-	headEntry, err := lru.ll.Entry(startIdx)
-	if err != nil {
-		atomic.AddUint64(&lru.metrics.Errors, 1)
-		return fmt.Errorf("removeEntryFromBuk err: %s", err.Error())
+	headEntry := delEntry
+	if startIdx != delIdx {
+		var err error
+		headEntry, err = lru.ll.Entry(startIdx)
+		if err != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			return fmt.Errorf("removeEntryFromBuk err: %s", err.Error())
+		}
 	}
 	tailIdx := headEntry.ConflictPrev
 	if delIdx == startIdx && delIdx == tailIdx {
@@ -265,38 +636,122 @@ func (lru *LRU[K, V]) removeEntryFromBuk(pos uint32, delIdx uint32) error {
 	return nil
 }
 
-func (lru *LRU[K, V]) hashToPos(key K) (hashId uint32, bukPos uint32) {
+func (lru *LRU[K, V]) hashToPos(key K) (hashId uint64, bukPos uint32) {
 	hashId = lru.hashFunc(key)
 	return hashId, lru.getBucketPos(hashId)
 }
 
-// Add adds a value to the cache.
+// lockKey takes the write lock and returns the position of key. The key is
+// hashed before locking, unless the seed may change under the lock, then a
+// pending reseed is done first.
+func (lru *LRU[K, V]) lockKey(key K) (hashId uint64, bukPos uint32) {
+	if lru.seeded == nil {
+		hashId, bukPos = lru.hashToPos(key)
+	}
+	lru.Lock()
+	if lru.seeded != nil && !lru.closed {
+		if lru.maybeReseed() != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+		}
+		hashId, bukPos = lru.hashToPos(key)
+	}
+	return hashId, bukPos
+}
+
+// rlockKey is lockKey with the read lock.
+func (lru *LRU[K, V]) rlockKey(key K) (hashId uint64, bukPos uint32) {
+	if lru.seeded == nil {
+		hashId, bukPos = lru.hashToPos(key)
+	}
+	lru.RLock()
+	if lru.seeded != nil {
+		hashId, bukPos = lru.hashToPos(key)
+	}
+	return hashId, bukPos
+}
+
+// Add adds a value to the cache. In write-through mode the value is saved to
+// the Store first, and the cache is left unchanged if that fails. If the cache
+// does not take the saved value ErrSavedNotCached is returned, wrapping the
+// cause.
 func (lru *LRU[K, V]) Add(key K, value V, priority byte) error {
+	if lru.writeThrough {
+		mu := lru.keyLocks.lock(key)
+		if err := lru.saveThrough(key, value); err != nil {
+			mu.Unlock()
+			return err
+		}
+		if err := lru.add(key, value, priority, mu); err != nil {
+			return fmt.Errorf("%w: %w", ErrSavedNotCached, err)
+		}
+		return nil
+	}
+	for {
+		err := lru.add(key, value, priority, nil)
+		if !lru.waitSaves(err) {
+			return err
+		}
+	}
+}
+
+// add takes over keyLock, if not nil, and unlock releases it before the
+// callbacks, which may write the same key.
+func (lru *LRU[K, V]) add(key K, value V, priority byte, keyLock *sync.Mutex) error {
 	if priority > lru.maxPriority {
 		priority = lru.maxPriority
 	}
-	hashId, bukPos := lru.hashToPos(key)
-	lru.Lock()
-	defer lru.Unlock()
-	e, ok, err := lru.getEntryInBuk(bukPos, key)
-	if err != nil {
-		return fmt.Errorf("add err: %s", err.Error())
+	var hook hookCall[K, V]
+	if lru.hooks != nil && !lru.hooks.InLock {
+		defer lru.runHook(&hook)
+	}
+	hashId, bukPos := lru.lockKey(key)
+	lru.keyLock = keyLock
+	defer lru.unlock()
+	if lru.hooks != nil && lru.hooks.InLock {
+		defer lru.runHook(&hook)
+	}
+	return lru.addLocked(&hook, key, value, hashId, bukPos, priority)
+}
+
+// addLocked is add with the lock held. The defers stay in add, which has a
+// single return, so the compiler open-codes them.
+func (lru *LRU[K, V]) addLocked(hook *hookCall[K, V], key K, value V, hashId uint64, bukPos uint32, priority byte) error {
+	if lru.closed {
+		return ErrClosed
+	}
+	if lru.readBufs != nil {
+		lru.drainReadBuffers()
+	}
+	if lru.sketch != nil {
+		lru.sketch.age()
+		lru.sketch.increment(hashId)
 	}
-	markNode, err := lru.getPriorityMarkNode(priority + 1)
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
 	if err != nil {
 		return fmt.Errorf("add err: %s", err.Error())
 	}
 	if ok {
-		err = lru.ll.MoveAfter(e, markNode)
-		if err != nil {
-			atomic.AddUint64(&lru.metrics.Errors, 1)
-			return fmt.Errorf("add err: %s", err.Error())
+		moved := e.Priority != priority
+		lru.setPriority(e, priority)
+		lru.payloadSub(e)
+		if lru.cloneKey == nil {
+			e.Key = key
 		}
-		e.Priority = priority
-		e.Key = key
 		e.HashId = hashId
+		old := e.Value
 		e.Value = value
-		err = lru.ll.UpdateEntry(e.Idx(), e)
+		lru.payloadAdd(e)
+		lru.updated(hook, key, old, value)
+		lru.changed(EventUpdate, hashId, key, value, priority)
+		if lru.writeBack != nil {
+			lru.markDirty(e)
+		}
+		if lru.sieve != nil {
+			lru.reference(e)
+		}
+		if lru.sieve == nil || moved {
+			err = lru.touch(e)
+		}
 		if err != nil {
 			atomic.AddUint64(&lru.metrics.Errors, 1)
 			return fmt.Errorf("add err: %s", err.Error())
@@ -304,72 +759,258 @@ func (lru *LRU[K, V]) Add(key K, value V, priority byte) error {
 		atomic.AddUint64(&lru.metrics.Inserts, 1)
 		return nil
 	}
-	if lru.ll.Len() >= lru.ll.Cap() {
-		lru.removeOldest()
+	ele, err := lru.insertEntry(key, value, hashId, bukPos, priority, false)
+	if err == ErrRejected || err == errSaveInFlight || err == errKeyBusy {
+		return err
 	}
-	ele, err := lru.ll.InsertAfter(key, value, markNode)
 	if err != nil {
-		atomic.AddUint64(&lru.metrics.Errors, 1)
 		return fmt.Errorf("add err: %s", err.Error())
 	}
-	ele.HashId = hashId
-	err = lru.addEntryInBuk(bukPos, ele.Idx())
-	if err != nil {
-		lru.ll.Remove(ele)
-		return fmt.Errorf("add err: %s", err.Error())
+	if lru.writeBack != nil {
+		lru.markDirty(ele)
 	}
-	atomic.AddUint64(&lru.metrics.Inserts, 1)
+	lru.inserted(hook, key, value)
+	lru.changed(EventAdd, hashId, key, value, priority)
 	return nil
 }
 
 func (lru *LRU[K, V]) AddToBack(key K, value V, priority byte) error {
+	if lru.writeThrough {
+		mu := lru.keyLocks.lock(key)
+		if err := lru.saveThrough(key, value); err != nil {
+			mu.Unlock()
+			return err
+		}
+		if err := lru.addToBack(key, value, priority, mu); err != nil {
+			return fmt.Errorf("%w: %w", ErrSavedNotCached, err)
+		}
+		return nil
+	}
+	for {
+		err := lru.addToBack(key, value, priority, nil)
+		if !lru.waitSaves(err) {
+			return err
+		}
+	}
+}
+
+func (lru *LRU[K, V]) addToBack(key K, value V, priority byte, keyLock *sync.Mutex) error {
 	if priority > lru.maxPriority {
 		priority = lru.maxPriority
 	}
-	hashId, bukPos := lru.hashToPos(key)
-	lru.Lock()
-	defer lru.Unlock()
-	e, ok, err := lru.getEntryInBuk(bukPos, key)
-	if err != nil {
-		return fmt.Errorf("addToBack err: %s", err.Error())
+	var hook hookCall[K, V]
+	if lru.hooks != nil && !lru.hooks.InLock {
+		defer lru.runHook(&hook)
+	}
+	hashId, bukPos := lru.lockKey(key)
+	lru.keyLock = keyLock
+	defer lru.unlock()
+	if lru.hooks != nil && lru.hooks.InLock {
+		defer lru.runHook(&hook)
 	}
-	markNode, err := lru.getPriorityMarkNode(priority)
+	return lru.addToBackLocked(&hook, key, value, hashId, bukPos, priority)
+}
+
+// addToBackLocked is addToBack with the lock held. The defers stay in
+// addToBack, which has a single return, so the compiler open-codes them.
+func (lru *LRU[K, V]) addToBackLocked(hook *hookCall[K, V], key K, value V, hashId uint64, bukPos uint32, priority byte) error {
+	if lru.closed {
+		return ErrClosed
+	}
+	if lru.readBufs != nil {
+		lru.drainReadBuffers()
+	}
+	if lru.sketch != nil {
+		lru.sketch.age()
+		lru.sketch.increment(hashId)
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
 	if err != nil {
 		return fmt.Errorf("addToBack err: %s", err.Error())
 	}
-	if ok {
+	if ok && lru.sieve != nil {
+		lru.sieveUnhand(e)
+	}
+	if ok && lru.lfu == nil {
+		markNode, err := lru.backMarkNode(priority, lru.segment(e))
+		if err != nil {
+			return fmt.Errorf("addToBack err: %s", err.Error())
+		}
 		err = lru.ll.MoveBefore(e, markNode)
 		if err != nil {
 			atomic.AddUint64(&lru.metrics.Errors, 1)
 			return fmt.Errorf("addToBack err: %s", err.Error())
 		}
+	}
+	if ok {
+		lru.payloadSub(e)
 		e.HashId = hashId
-		e.Priority = priority
-		e.Key = key
+		if lru.cloneKey == nil {
+			e.Key = key
+		}
+		old := e.Value
 		e.Value = value
-		err = lru.ll.UpdateEntry(e.Idx(), e)
-		if err != nil {
-			atomic.AddUint64(&lru.metrics.Errors, 1)
-			return fmt.Errorf("addToBack err: %s", err.Error())
+		lru.payloadAdd(e)
+		lru.updated(hook, key, old, value)
+		lru.setPriority(e, priority)
+		lru.changed(EventUpdate, hashId, key, value, priority)
+		if lru.writeBack != nil {
+			lru.markDirty(e)
+		}
+		if lru.slru != nil {
+			lru.slruDemote(priority)
 		}
 		atomic.AddUint64(&lru.metrics.Inserts, 1)
 		return nil
 	}
-	if lru.ll.Len() >= lru.ll.Cap() {
-		lru.removeOldest()
+	ele, err := lru.insertEntry(key, value, hashId, bukPos, priority, true)
+	if err == ErrRejected || err == errSaveInFlight || err == errKeyBusy {
+		return err
 	}
-	ele, err := lru.ll.InsertBefore(key, value, markNode)
 	if err != nil {
 		return fmt.Errorf("addToBack err: %s", err.Error())
 	}
+	if lru.writeBack != nil {
+		lru.markDirty(ele)
+	}
+	lru.inserted(hook, key, value)
+	lru.changed(EventAdd, hashId, key, value, priority)
+	return nil
+}
+
+// insertEntry links a new entry at the front, or the back if toBack is set, of
+// its priority band. The oldest entry is evicted first when the cache is full.
+// It returns errSaveInFlight if the room is kept by victims being saved, and
+// errKeyBusy if the dirty victims left were skipped as their keys are busy.
+func (lru *LRU[K, V]) insertEntry(key K, value V, hashId uint64, bukPos uint32, priority byte, toBack bool) (*jlist.Entry[K, V], error) {
+	if lru.sketch != nil && lru.full() && !lru.admit(hashId, priority) {
+		atomic.AddUint64(&lru.metrics.Rejections, 1)
+		return nil, ErrRejected
+	}
+	if lru.writeBack != nil && lru.full() && lru.detachVictim() {
+		lru.restoreSkipped()
+		return nil, errSaveInFlight
+	}
+	seg := segRecent
+	if lru.arc != nil {
+		seg = lru.arcAdmit(hashId)
+	}
+	if lru.full() {
+		lru.removeOldest()
+		if lru.writeBack != nil {
+			skipped := lru.restoreSkipped()
+			if lru.full() && lru.writeBack.saving > 0 {
+				return nil, errSaveInFlight
+			}
+			if lru.full() && skipped {
+				return nil, errKeyBusy
+			}
+		}
+	}
+	if lru.cloneKey != nil {
+		key = lru.cloneKey(key)
+	}
+	var ele, markNode *jlist.Entry[K, V]
+	var err error
+	switch {
+	case toBack:
+		markNode, err = lru.backMarkNode(priority, seg)
+	case lru.lfu != nil:
+		markNode, err = lru.lfuFrontMarkNode(priority)
+	default:
+		markNode, err = lru.frontMarkNode(priority, seg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if toBack || lru.lfu != nil {
+		ele, err = lru.ll.InsertBefore(key, value, markNode)
+	} else {
+		ele, err = lru.ll.InsertAfter(key, value, markNode)
+	}
+	if err != nil {
+		atomic.AddUint64(&lru.metrics.Errors, 1)
+		return nil, err
+	}
+	ele.Priority = priority
 	ele.HashId = hashId
-	err = lru.addEntryInBuk(bukPos, ele.Idx())
+	if ele.Idx() >= lru.side {
+		lru.growSide()
+	}
+	if lru.refs != nil {
+		lru.refs[ele.Idx()] = 0
+	}
+	err = lru.addEntryInBuk(bukPos, ele)
 	if err != nil {
 		lru.ll.Remove(ele)
-		return fmt.Errorf("addToBack err: %s", err.Error())
+		return nil, err
+	}
+	lru.payloadAdd(ele)
+	if lru.seg != nil {
+		lru.segs[ele.Idx()] = seg
+		lru.segAdd(priority, seg)
+	}
+	if lru.lfu != nil {
+		lru.lfuLink(ele, toBack)
+	}
+	if lru.gdsf != nil {
+		lru.freqs[ele.Idx()] = 1
+		lru.gdsfUpdate(ele)
+	}
+	if lru.lruK != nil {
+		lru.lruKLink(ele)
 	}
 	atomic.AddUint64(&lru.metrics.Inserts, 1)
-	return nil
+	return ele, nil
+}
+
+func (lru *LRU[K, V]) segAdd(priority byte, seg byte) {
+	lru.segLen[seg]++
+	lru.bandSeg[priority][seg]++
+}
+
+// segment returns the segment of e, segRecent for the unsegmented policies.
+func (lru *LRU[K, V]) segment(e *jlist.Entry[K, V]) byte {
+	if lru.segs == nil {
+		return segRecent
+	}
+	return lru.segs[e.Idx()]
+}
+
+func (lru *LRU[K, V]) segDel(priority byte, seg byte) {
+	lru.segLen[seg]--
+	lru.bandSeg[priority][seg]--
+}
+
+// setPriority moves e to another priority band, the caller relinks it unless
+// the policy keeps its own order inside the band.
+func (lru *LRU[K, V]) setPriority(e *jlist.Entry[K, V], priority byte) {
+	if lru.lfu != nil && e.Priority != priority {
+		lru.lfuMove(e, priority)
+		return
+	}
+	if lru.sieve != nil && e.Priority != priority {
+		lru.sieveUnhand(e)
+	}
+	if lru.seg != nil && e.Priority != priority {
+		lru.segDel(e.Priority, lru.segs[e.Idx()])
+		lru.segAdd(priority, lru.segs[e.Idx()])
+	}
+	e.Priority = priority
+	if lru.gdsf != nil {
+		lru.gdsfUpdate(e)
+	}
+	if lru.lruK != nil {
+		lru.lruKUpdate(e)
+	}
+}
+
+func (lru *LRU[K, V]) getSegmentMarkNode(priority byte) (*jlist.Entry[K, V], error) {
+	markNode, err := lru.ll.Entry(lru.seg[priority])
+	if err != nil {
+		return nil, fmt.Errorf("getSegmentMarkNode err: %s", err.Error())
+	}
+	return markNode, nil
 }
 
 func (lru *LRU[K, V]) getPriorityMarkNode(priority byte) (*jlist.Entry[K, V], error) {
@@ -381,40 +1022,157 @@ func (lru *LRU[K, V]) getPriorityMarkNode(priority byte) (*jlist.Entry[K, V], er
 	return markNode, nil
 }
 
+// frontMarkNode returns the node after which an entry is at the front of
+// segment seg of its priority band.
+func (lru *LRU[K, V]) frontMarkNode(priority byte, seg byte) (*jlist.Entry[K, V], error) {
+	if lru.seg == nil || seg == segFrequent {
+		return lru.getPriorityMarkNode(priority + 1)
+	}
+	return lru.getSegmentMarkNode(priority)
+}
+
+// backMarkNode returns the node before which an entry is at the back of
+// segment seg of its priority band.
+func (lru *LRU[K, V]) backMarkNode(priority byte, seg byte) (*jlist.Entry[K, V], error) {
+	if lru.seg == nil || seg == segRecent {
+		return lru.getPriorityMarkNode(priority)
+	}
+	return lru.getSegmentMarkNode(priority)
+}
+
 // Get looks up a key's value from the cache.
 func (lru *LRU[K, V]) Get(key K) (value V, ok bool, err error) {
-	_, bukPos := lru.hashToPos(key)
-	lru.Lock()
+	value, _, ok, err = lru.GetWithFrequency(key)
+	return value, ok, err
+}
+
+// GetWithFrequency looks up a key's value from the cache, it also reports the
+// access frequency counted for the entry, which is only kept by PolicyLFU and PolicyGDSF.
+func (lru *LRU[K, V]) GetWithFrequency(key K) (value V, freq uint32, ok bool, err error) {
+	if lru.hooks != nil && !lru.hooks.InLock {
+		defer func() { lru.lookedUp(key, value, ok, err) }()
+	}
+	if lru.policy == PolicyClock || lru.policy == PolicySIEVE || lru.readBufs != nil {
+		return lru.getShared(key)
+	}
+	hashId, bukPos := lru.lockKey(key)
 	defer lru.Unlock()
-	e, ok, err := lru.getEntryInBuk(bukPos, key)
+	if lru.hooks != nil && lru.hooks.InLock {
+		defer func() { lru.lookedUp(key, value, ok, err) }()
+	}
+	if lru.closed {
+		return value, 0, false, ErrClosed
+	}
+	if lru.sketch != nil {
+		lru.sketch.increment(hashId)
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
 	if err != nil {
-		return value, false, fmt.Errorf("get err: %s", err.Error())
+		return value, 0, false, fmt.Errorf("get err: %s", err.Error())
 	}
 	if ok {
-		markNode, err := lru.getPriorityMarkNode(e.Priority + 1)
-		if err != nil {
-			atomic.AddUint64(&lru.metrics.Errors, 1)
-			return value, false, fmt.Errorf("get err: %s", err.Error())
-		}
 		atomic.AddUint64(&lru.metrics.Hits, 1)
 		value = e.Value
-		err = lru.ll.MoveAfter(e, markNode)
+		err = lru.touch(e)
 		if err != nil {
 			atomic.AddUint64(&lru.metrics.Errors, 1)
-			return value, true, fmt.Errorf("get err: %s", err.Error())
+			return value, lru.frequency(e), true, fmt.Errorf("get err: %s", err.Error())
 		}
-		return value, true, nil
+		return value, lru.frequency(e), true, nil
 	}
 	atomic.AddUint64(&lru.metrics.Misses, 1)
-	return value, false, nil
+	return value, 0, false, nil
+}
+
+// frequency returns the access frequency of e, 0 unless the policy counts it.
+func (lru *LRU[K, V]) frequency(e *jlist.Entry[K, V]) uint32 {
+	if lru.freqs == nil {
+		return 0
+	}
+	return lru.freqs[e.Idx()]
+}
+
+// getShared serves Get under the read lock, the hit is only recorded and
+// applied to the lru order later.
+func (lru *LRU[K, V]) getShared(key K) (value V, freq uint32, ok bool, err error) {
+	hashId, bukPos := lru.rlockKey(key)
+	if lru.closed {
+		lru.RUnlock()
+		return value, 0, false, ErrClosed
+	}
+	if lru.sketch != nil {
+		lru.sketch.increment(hashId)
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
+	if err != nil {
+		lru.RUnlock()
+		return value, 0, false, fmt.Errorf("get err: %s", err.Error())
+	}
+	if !ok {
+		if lru.hooks != nil && lru.hooks.InLock {
+			lru.lookedUp(key, value, false, nil)
+		}
+		lru.RUnlock()
+		atomic.AddUint64(&lru.metrics.Misses, 1)
+		return value, 0, false, nil
+	}
+	value = e.Value
+	freq = lru.frequency(e)
+	var drain bool
+	if lru.readBufs != nil {
+		drain = lru.recordAccess(e)
+	} else {
+		lru.reference(e)
+	}
+	if lru.hooks != nil && lru.hooks.InLock {
+		lru.lookedUp(key, value, true, nil)
+	}
+	lru.RUnlock()
+	atomic.AddUint64(&lru.metrics.Hits, 1)
+	if drain && lru.TryLock() {
+		lru.drainReadBuffers()
+		lru.Unlock()
+	}
+	return value, freq, true, nil
+}
+
+// touch moves a hit entry to the front of its priority band, segmented
+// policies may move it to another segment first.
+func (lru *LRU[K, V]) touch(e *jlist.Entry[K, V]) error {
+	switch {
+	case lru.arc != nil:
+		lru.arcHit(e)
+	case lru.slru != nil:
+		lru.slruHit(e)
+	case lru.lfu != nil:
+		return lru.lfuHit(e)
+	case lru.gdsf != nil:
+		lru.gdsfHit(e)
+	case lru.lruK != nil:
+		lru.lruKHit(e)
+	}
+	markNode, err := lru.frontMarkNode(e.Priority, lru.segment(e))
+	if err != nil {
+		return fmt.Errorf("touch err: %s", err.Error())
+	}
+	err = lru.ll.MoveAfter(e, markNode)
+	if err != nil {
+		return err
+	}
+	if lru.slru != nil {
+		return lru.slruDemote(e.Priority)
+	}
+	return nil
 }
 
 // Has looks up a key's value from the cache.
 func (lru *LRU[K, V]) Has(key K) (value V, ok bool, err error) {
-	_, bukPos := lru.hashToPos(key)
-	lru.RLock()
+	hashId, bukPos := lru.rlockKey(key)
 	defer lru.RUnlock()
-	ele, ok, err := lru.getEntryInBuk(bukPos, key)
+	if lru.closed {
+		return value, false, ErrClosed
+	}
+	ele, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
 	if err != nil {
 		return value, false, fmt.Errorf("has err: %s", err.Error())
 	}
@@ -425,12 +1183,36 @@ func (lru *LRU[K, V]) Has(key K) (value V, ok bool, err error) {
 	return value, false, nil
 }
 
-// Remove removes the provided key from the cache.
+// Remove removes the provided key from the cache, and from the Store in
+// write-back mode, after the saves of the key in flight. In write-through mode
+// the key is deleted from the Store first, and the cache is left unchanged if
+// that fails.
 func (lru *LRU[K, V]) Remove(key K) (value V, ok bool, err error) {
-	_, bukPos := lru.hashToPos(key)
-	lru.Lock()
+	if lru.keyLocks != nil {
+		defer lru.keyLocks.lock(key).Unlock()
+	}
+	if lru.writeThrough {
+		if err = lru.deleteThrough(key); err != nil {
+			return value, false, err
+		}
+		return lru.remove(key)
+	}
+	value, ok, err = lru.remove(key)
+	if err == nil && lru.writeBack != nil {
+		if err = lru.store.Delete(context.Background(), key); err != nil {
+			return value, ok, fmt.Errorf("remove err: %s", err.Error())
+		}
+	}
+	return value, ok, err
+}
+
+func (lru *LRU[K, V]) remove(key K) (value V, ok bool, err error) {
+	hashId, bukPos := lru.lockKey(key)
 	defer lru.Unlock()
-	e, ok, err := lru.getEntryInBuk(bukPos, key)
+	if lru.closed {
+		return value, false, ErrClosed
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
 	if err != nil {
 		return value, false, fmt.Errorf("remove err: %s", err.Error())
 	}
@@ -441,7 +1223,7 @@ func (lru *LRU[K, V]) Remove(key K) (value V, ok bool, err error) {
 		atomic.AddUint64(&lru.metrics.Errors, 1)
 		return value, false, errors.New("remove err: not user node")
 	}
-	if e.Key != key {
+	if !lru.equal(e.Key, key) {
 		atomic.AddUint64(&lru.metrics.Conflict, 1)
 		return value, false, errors.New("remove err: key conflict")
 	}
@@ -455,8 +1237,18 @@ func (lru *LRU[K, V]) Remove(key K) (value V, ok bool, err error) {
 	return value, true, nil
 }
 
+// full reports whether an insert needs an eviction first, the victims being
+// saved keep their room.
+func (lru *LRU[K, V]) full() bool {
+	n := lru.ll.Len()
+	if lru.writeBack != nil {
+		n += uint32(lru.writeBack.saving)
+	}
+	return n >= lru.ll.Cap()
+}
+
 func (lru *LRU[K, V]) removeOldest() {
-	ele := lru.oldest()
+	ele := lru.victim()
 	if ele != nil {
 		if lru.removeElement(ele, true) == nil {
 			atomic.AddUint64(&lru.metrics.Evictions, 1)
@@ -468,19 +1260,38 @@ func (lru *LRU[K, V]) removeOldest() {
 // RemoveOldest removes the oldest item from the cache.
 func (lru *LRU[K, V]) RemoveOldest() bool {
 	lru.Lock()
-	defer lru.Unlock()
-	ele := lru.oldest()
-	if ele != nil {
-		if lru.removeElement(ele, true) == nil {
-			atomic.AddUint64(&lru.metrics.Removals, 1)
-			return true
-		}
+	defer lru.unlock()
+	if lru.closed {
 		return false
 	}
-	return false
+	saves := len(lru.saves)
+	ele := lru.victim()
+	removed := ele != nil && lru.removeElement(ele, true) == nil
+	if lru.writeBack != nil {
+		lru.restoreSkipped()
+	}
+	if removed {
+		atomic.AddUint64(&lru.metrics.Removals, 1)
+		return true
+	}
+	return len(lru.saves) > saves
 }
 
 func (lru *LRU[K, V]) oldest() *jlist.Entry[K, V] {
+	switch lru.policy {
+	case PolicyClock:
+		return lru.clockOldest()
+	case PolicyARC:
+		return lru.arcOldest()
+	case PolicySLRU:
+		return lru.slruOldest()
+	case PolicyGDSF:
+		return lru.gdsfOldest()
+	case PolicyLRU2:
+		return lru.lruKOldest()
+	case PolicySIEVE:
+		return lru.sieveOldest()
+	}
 	var i byte
 	for i = 0; i < lru.maxPriority; i++ {
 		markNode, err := lru.getPriorityMarkNode(i)
@@ -499,6 +1310,38 @@ func (lru *LRU[K, V]) oldest() *jlist.Entry[K, V] {
 	return nil
 }
 
+// peekOldest returns the entry oldest would pick, without the side effects of
+// the clock and sieve scans on the reference bits, the order and the hands.
+func (lru *LRU[K, V]) peekOldest() *jlist.Entry[K, V] {
+	switch lru.policy {
+	case PolicyClock:
+		return lru.clockPeek()
+	case PolicySIEVE:
+		return lru.sievePeek()
+	}
+	return lru.oldest()
+}
+
+// segmentOldest returns the oldest entry of segment seg in the lowest priority
+// band which has one.
+func (lru *LRU[K, V]) segmentOldest(seg byte) *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		markNode, err := lru.backMarkNode(i, seg)
+		if err != nil {
+			return nil
+		}
+		e, err := lru.ll.Entry(markNode.Prev())
+		if err != nil {
+			return nil
+		}
+		if e.Flag == 0 {
+			return e
+		}
+	}
+	return nil
+}
+
 func (lru *LRU[K, V]) removeElement(e *jlist.Entry[K, V], evict bool) error {
 	if e == nil {
 		return nil
@@ -507,38 +1350,66 @@ func (lru *LRU[K, V]) removeElement(e *jlist.Entry[K, V], evict bool) error {
 		return errors.New("removeElement err: not user node")
 	}
 	bukPos := lru.getBucketPos(e.HashId)
-	if evict && lru.OnEvicted != nil {
-		if lru.OnEvicted(e.Key, e.Value) {
-			err := lru.removeEntryFromBuk(bukPos, e.Idx())
-			if err != nil {
-				return fmt.Errorf("removeElement err:%s", err.Error())
-			}
-			_, err = lru.ll.Remove(e)
-			if err != nil {
-				return fmt.Errorf("removeElement err:%s", err.Error())
-			}
+	if evict && lru.OnEvicted != nil && lru.delivery == DeliverInLock {
+		if !lru.OnEvicted(e.Key, e.Value) {
+			return nil
 		}
-		return nil
 	}
-	err := lru.removeEntryFromBuk(bukPos, e.Idx())
+	key, priority, hashId := e.Key, e.Priority, e.HashId
+	err := lru.removeEntryFromBuk(bukPos, e)
 	if err != nil {
 		return fmt.Errorf("removeElement err:%s", err.Error())
 	}
-	_, err = lru.ll.Remove(e)
+	lru.forget(e, evict)
+	value, err := lru.ll.Remove(e)
 	if err != nil {
 		return fmt.Errorf("removeElement err:%s", err.Error())
 	}
+	if evict && (lru.OnEvicted != nil || lru.OnEvictedReason != nil) {
+		lru.evicted(key, value, EvictCapacity)
+	}
+	if evict {
+		lru.changed(EventEvict, hashId, key, value, priority)
+	} else {
+		lru.changed(EventRemove, hashId, key, value, priority)
+	}
 	return nil
 }
 
+// forget drops the policy state of an entry which is leaving the cache.
+func (lru *LRU[K, V]) forget(e *jlist.Entry[K, V], evict bool) {
+	lru.payloadSub(e)
+	if lru.writeBack != nil {
+		lru.dropDirty(e)
+	}
+	if lru.seg != nil {
+		lru.segDel(e.Priority, lru.segs[e.Idx()])
+	}
+	if lru.arc != nil && evict {
+		lru.arcGhost(e)
+	}
+	if lru.lfu != nil {
+		lru.lfuUnlink(e)
+	}
+	if lru.gdsf != nil {
+		lru.gdsfForget(e, evict)
+	}
+	if lru.lruK != nil {
+		lru.lruKForget(e, evict)
+	}
+	if lru.sieve != nil {
+		lru.sieveUnhand(e)
+	}
+}
+
 // Len returns the number of items in the cache.
 func (lru *LRU[K, V]) Len() uint32 {
 	lru.RLock()
 	defer lru.RUnlock()
-	if lru.ll == nil {
+	if lru.closed {
 		return 0
 	}
-	return lru.ll.Len() - uint32(lru.maxPriority) - 2
+	return lru.ll.Len() - lru.markers
 }
 
 func (lru *LRU[K, V]) Metrics() ListMetrics {
@@ -550,6 +1421,9 @@ func (lru *LRU[K, V]) Metrics() ListMetrics {
 func (lru *LRU[K, V]) Iterate() (keys []K, values []V, priority []byte) {
 	lru.RLock()
 	defer lru.RUnlock()
+	if lru.closed {
+		return nil, nil, nil
+	}
 	for pos, startIdx := range lru.buckets {
 		if startIdx == emptyBucket {
 			continue
@@ -571,31 +1445,153 @@ func (lru *LRU[K, V]) Iterate() (keys []K, values []V, priority []byte) {
 }
 
 func (lru *LRU[K, V]) Cap() uint32 {
-	return lru.ll.Cap() - uint32(lru.maxPriority) - 2
+	return lru.cap
 }
 
-// Clear purges all stored items from the cache.
+// Clear purges all stored items from the cache like Purge and releases its
+// memory like Close.
+//
+// Deprecated: use Purge to keep using the cache, or Close. In write-back mode
+// the cache is left as is if the dirty entries fail to save.
 func (lru *LRU[K, V]) Clear() {
-	for pos, startIdx := range lru.buckets {
-		if startIdx == emptyBucket {
+	if lru.lockFlushed() != nil {
+		return
+	}
+	if lru.closed {
+		lru.Unlock()
+		return
+	}
+	lru.purge()
+	lru.release()
+	lru.unlock()
+	if lru.evictQueue != nil {
+		lru.evictQueue.stop()
+	}
+}
+
+// Purge removes all the entries and keeps the memory of the cache for reuse.
+// OnEvicted and OnEvictedReason are called for every entry, the latter with
+// EvictCleared, and the result of OnEvicted is ignored. In write-back mode the
+// dirty entries are flushed first, if that fails the error of Flush is
+// returned and the cache is left as is.
+func (lru *LRU[K, V]) Purge() error {
+	if err := lru.lockFlushed(); err != nil {
+		return err
+	}
+	defer lru.unlock()
+	if lru.closed {
+		return ErrClosed
+	}
+	lru.purge()
+	return lru.reset()
+}
+
+// Close drops the entries without calling OnEvicted and releases the memory of
+// the cache. The later calls return ErrClosed, or report an empty cache. With
+// DeliverAsync it waits for the queued evictions to be delivered. In
+// write-back mode the dirty entries are flushed first, if that fails the error
+// of Flush is returned and the cache stays open.
+func (lru *LRU[K, V]) Close() error {
+	if err := lru.lockFlushed(); err != nil {
+		return err
+	}
+	if lru.closed {
+		lru.Unlock()
+		return ErrClosed
+	}
+	lru.release()
+	lru.Unlock()
+	if lru.evictQueue != nil {
+		lru.evictQueue.stop()
+	}
+	return nil
+}
+
+// purge delivers the callbacks of all the entries in eviction order.
+func (lru *LRU[K, V]) purge() {
+	if lru.OnEvicted == nil && lru.OnEvictedReason == nil && len(lru.subs) == 0 && len(lru.watches) == 0 {
+		return
+	}
+	markNode, err := lru.getPriorityMarkNode(0)
+	if err != nil {
+		atomic.AddUint64(&lru.metrics.Errors, 1)
+		return
+	}
+	for idx := markNode.Prev(); idx != markNode.Idx(); {
+		e, err := lru.ll.Entry(idx)
+		if err != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			return
+		}
+		idx = e.Prev()
+		if e.Flag != 0 {
 			continue
 		}
-		idx := startIdx
-		for lru.buckets[pos] != emptyBucket {
-			e, err := lru.ll.Entry(idx)
-			if err == nil {
-				if lru.OnEvicted != nil {
-					if !lru.OnEvicted(e.Key, e.Value) {
-						continue
-					}
-				}
-				idx = e.ConflictNext
-				_ = lru.removeEntryFromBuk(uint32(pos), e.Idx())
-				_, _ = lru.ll.Remove(e)
-			}
+		if lru.OnEvicted != nil && lru.delivery == DeliverInLock {
+			lru.OnEvicted(e.Key, e.Value)
+		}
+		if lru.OnEvicted != nil || lru.OnEvictedReason != nil {
+			lru.evicted(e.Key, e.Value, EvictCleared)
 		}
+		lru.changed(EventRemove, e.HashId, e.Key, e.Value, e.Priority)
+	}
+}
+
+// reset empties the cache in place, the arena, the buckets and the policy
+// state keep their memory.
+func (lru *LRU[K, V]) reset() error {
+	lru.ll.Reset()
+	lru.payload = 0
+	lru.segLen = [2]uint32{}
+	for i := range lru.bandSeg {
+		lru.bandSeg[i] = [2]uint32{}
+	}
+	for i := range lru.readBufs {
+		lru.readBufs[i].writes = 0
+	}
+	if lru.arc != nil {
+		lru.arc.reset()
+	}
+	if lru.lfu != nil {
+		lru.lfu.reset()
+	}
+	if lru.gdsf != nil {
+		lru.gdsf.reset()
+	}
+	if lru.lruK != nil {
+		lru.lruK.reset()
+	}
+	if lru.sieve != nil {
+		lru.sieve.reset()
+	}
+	if lru.sketch != nil {
+		lru.sketch.reset()
+	}
+	if lru.writeBack != nil {
+		lru.writeBack.reset()
+	}
+	return lru.initMarkers()
+}
+
+// release drops the memory of a closed cache. The fields read without the
+// lock, like readBufs and seeded, are kept.
+func (lru *LRU[K, V]) release() {
+	lru.closed = true
+	if lru.writeBack != nil && lru.writeBack.timer != nil {
+		lru.writeBack.timer.Stop()
 	}
+	lru.closeSubscribers()
+	lru.closeWatches()
 	lru.ll.Clear()
 	lru.ll = nil
 	lru.buckets = nil
+	lru.arc = nil
+	lru.lfu = nil
+	lru.gdsf = nil
+	lru.lruK = nil
+	lru.refs = nil
+	lru.segs = nil
+	lru.freqs = nil
+	lru.sizes = nil
+	lru.sketch = nil
 }
@@ -279,6 +279,154 @@ func TestLRU_Clear(t *testing.T) {
 	assert.Nil(t, lru.buckets) // Clear后buckets置空
 }
 
+func TestLRU_ClearCallback(t *testing.T) {
+	// OnEvicted返回false时Clear不会死循环，之后的调用返回ErrClosed
+	var evicted []string
+	lru, _ := NewPriorityLRU[string, []byte](2, 1, HashXXHASH, func(key string, value []byte) bool {
+		evicted = append(evicted, key)
+		return false
+	})
+	lru.Add("key1", []byte("val1"), 0)
+	lru.Clear()
+	assert.Equal(t, []string{"key1"}, evicted)
+	assert.Equal(t, ErrClosed, lru.Add("key1", []byte("val1"), 0))
+	_, _, err := lru.Get("key1")
+	assert.Equal(t, ErrClosed, err)
+}
+
+// ------------------------------ 9. Purge 与 Close ------------------------------
+func TestLRU_Purge(t *testing.T) {
+	t.Run("callback", func(t *testing.T) {
+		// 按驱逐顺序回调，原因为EvictCleared，OnEvicted的返回值被忽略
+		var evicted []string
+		var reasons []EvictReason
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](4, 2, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			OnEvicted: func(key string, value []byte) bool {
+				evicted = append(evicted, key)
+				return false
+			},
+			OnEvictedReason: func(key string, value []byte, reason EvictReason) {
+				reasons = append(reasons, reason)
+			},
+		})
+		lru.Add("key1", []byte("val1"), 1)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		assert.NoError(t, lru.Purge())
+		assert.Equal(t, []string{"key2", "key3", "key1"}, evicted)
+		assert.Equal(t, []EvictReason{EvictCleared, EvictCleared, EvictCleared}, reasons)
+		assert.Equal(t, uint32(0), lru.Len())
+		_, ok, _ := lru.Get("key1")
+		assert.False(t, ok)
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		// Purge后不重新分配内存，缓存可继续使用
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](100, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			SizeFunc: func(key string, value []byte) uint64 { return uint64(len(value)) },
+		})
+		for i := 0; i < 100; i++ {
+			lru.Add(fmt.Sprintf("key%d", i), []byte("val"), 0)
+		}
+		before := lru.MemoryUsage()
+		assert.NoError(t, lru.Purge())
+		after := lru.MemoryUsage()
+		assert.Equal(t, before.Arena, after.Arena)
+		assert.Equal(t, before.Buckets, after.Buckets)
+		assert.Equal(t, uint64(0), after.Payload)
+		for i := 0; i < 150; i++ {
+			assert.NoError(t, lru.Add(fmt.Sprintf("key%d", i), []byte("val"), 0))
+		}
+		assert.Equal(t, uint32(100), lru.Len())
+		assert.Equal(t, before.Arena, lru.MemoryUsage().Arena)
+	})
+
+	t.Run("policies", func(t *testing.T) {
+		// 各淘汰策略的状态在Purge后重置
+		for _, opts := range []Options[string, []byte]{
+			{Policy: PolicyLRU, ReadBuffer: true, TinyLFU: true},
+			{Policy: PolicyClock},
+			{Policy: PolicyARC},
+			{Policy: PolicySLRU},
+			{Policy: PolicyLFU},
+			{Policy: PolicyGDSF},
+			{Policy: PolicyLRU2},
+			{Policy: PolicySIEVE},
+		} {
+			opts.HashFunc = HashXXHASH
+			lru, _ := NewPriorityLRUWithOptions[string, []byte](10, 2, opts)
+			for round := 0; round < 2; round++ {
+				for i := 0; i < 30; i++ {
+					key := fmt.Sprintf("key%d", i%15)
+					if _, ok, _ := lru.Get(key); !ok {
+						err := lru.Add(key, []byte("val"), byte(i%2))
+						if err != ErrRejected {
+							assert.NoError(t, err)
+						}
+					}
+				}
+				assert.True(t, lru.Len() > 0)
+				assert.NoError(t, lru.Purge())
+				assert.Equal(t, uint32(0), lru.Len())
+				keys, _, _ := lru.Iterate()
+				assert.Empty(t, keys)
+			}
+			if lru.arc != nil {
+				assert.Equal(t, uint32(0), lru.arc.b1.Len()+lru.arc.b2.Len())
+			}
+			if lru.lruK != nil {
+				assert.Equal(t, uint32(0), lru.lruK.history.Len())
+			}
+		}
+	})
+
+	t.Run("evict_reason", func(t *testing.T) {
+		// 容量驱逐的原因为EvictCapacity
+		var reasons []EvictReason
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](1, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			OnEvictedReason: func(key string, value []byte, reason EvictReason) {
+				assert.Equal(t, "key1", key)
+				reasons = append(reasons, reason)
+			},
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Remove("key2")
+		assert.Equal(t, []EvictReason{EvictCapacity}, reasons)
+	})
+}
+
+func TestLRU_Close(t *testing.T) {
+	// Close后释放内存，之后的调用返回ErrClosed
+	lru, _ := NewPriorityLRU[string, []byte](2, 1, HashXXHASH, nil)
+	lru.Add("key1", []byte("val1"), 0)
+	assert.NoError(t, lru.Close())
+	assert.Nil(t, lru.ll)
+	assert.Equal(t, ErrClosed, lru.Close())
+	assert.Equal(t, ErrClosed, lru.Purge())
+	assert.Equal(t, ErrClosed, lru.Add("key1", []byte("val1"), 0))
+	assert.Equal(t, ErrClosed, lru.AddToBack("key1", []byte("val1"), 0))
+	_, _, err := lru.Get("key1")
+	assert.Equal(t, ErrClosed, err)
+	_, _, err = lru.Has("key1")
+	assert.Equal(t, ErrClosed, err)
+	_, _, err = lru.Remove("key1")
+	assert.Equal(t, ErrClosed, err)
+	assert.False(t, lru.RemoveOldest())
+	assert.Equal(t, uint32(0), lru.Len())
+	assert.Equal(t, uint32(2), lru.Cap())
+	assert.Equal(t, MemoryStats{}, lru.MemoryUsage())
+
+	// 读锁路径同样返回ErrClosed
+	clock := newPolicyLRU(2, 1, PolicyClock)
+	assert.NoError(t, clock.Close())
+	_, _, err = clock.Get("key1")
+	assert.Equal(t, ErrClosed, err)
+}
+
 func BenchmarkAddOperation(b *testing.B) {
 	lru, _ := NewPriorityLRU[string, []byte](1000, 5, HashXXHASH, nil)
 	b.ResetTimer()
@@ -0,0 +1,108 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+const defaultHistoryRatio = 0.5
+
+// lru-2 evicts the entry whose second most recent access is the oldest, the
+// entries accessed only once go first in lru order. The access times come
+// from a logical clock and the entries are kept in an indexed heap. The last
+// access time of evicted entries is remembered in a bounded history of hashes,
+// so a key which comes back soon is inserted with two accesses already.
+// Like the other policies, lower priorities go first and entries of the
+// highest priority are never evicted.
+type lruKState struct {
+	heap    *idxHeap
+	history *ghostList
+	tick    uint64
+	last    []uint64 // arena idx -> time of the last access
+	prev    []uint64 // arena idx -> time of the access before the last one
+}
+
+func newLruKState[K any, V any](ll *jlist.List[K, V], capacity int, ratio float64) *lruKState {
+	if ratio <= 0 {
+		ratio = defaultHistoryRatio
+	}
+	size := int(float64(capacity) * ratio)
+	if size < 1 {
+		size = 1
+	}
+	k := &lruKState{
+		history: newGhostList(size),
+		last:    []uint64{},
+		prev:    []uint64{},
+	}
+	k.heap = newIdxHeap(func(a, b uint32) bool {
+		ea, _ := ll.Entry(a)
+		eb, _ := ll.Entry(b)
+		if ea.Priority != eb.Priority {
+			return ea.Priority < eb.Priority
+		}
+		if k.prev[a] != k.prev[b] {
+			return k.prev[a] < k.prev[b]
+		}
+		return k.last[a] < k.last[b]
+	})
+	return k
+}
+
+func (k *lruKState) grow(n uint32, max uint32) {
+	k.last = growSlice(k.last, n, max)
+	k.prev = growSlice(k.prev, n, max)
+	k.heap.grow(n, max)
+}
+
+func (k *lruKState) reset() {
+	k.heap.reset()
+	k.history.reset()
+	k.tick = 0
+}
+
+// lruKUpdate restores the place of e in the heap after it changed.
+func (lru *LRU[K, V]) lruKUpdate(e *jlist.Entry[K, V]) {
+	if e.Priority >= lru.maxPriority {
+		lru.lruK.heap.remove(e.Idx())
+		return
+	}
+	lru.lruK.heap.fix(e.Idx())
+}
+
+func (lru *LRU[K, V]) lruKLink(e *jlist.Entry[K, V]) {
+	k := lru.lruK
+	k.tick++
+	k.last[e.Idx()] = k.tick
+	k.prev[e.Idx()] = 0
+	if last, ok := k.history.remove(e.HashId); ok {
+		k.prev[e.Idx()] = last
+	}
+	lru.lruKUpdate(e)
+}
+
+func (lru *LRU[K, V]) lruKHit(e *jlist.Entry[K, V]) {
+	k := lru.lruK
+	k.tick++
+	k.prev[e.Idx()] = k.last[e.Idx()]
+	k.last[e.Idx()] = k.tick
+	lru.lruKUpdate(e)
+}
+
+func (lru *LRU[K, V]) lruKForget(e *jlist.Entry[K, V], evict bool) {
+	lru.lruK.heap.remove(e.Idx())
+	if evict {
+		lru.lruK.history.push(e.HashId, lru.lruK.last[e.Idx()])
+	}
+}
+
+func (lru *LRU[K, V]) lruKOldest() *jlist.Entry[K, V] {
+	idx, ok := lru.lruK.heap.min()
+	if !ok {
+		return nil
+	}
+	e, err := lru.ll.Entry(idx)
+	if err != nil {
+		return nil
+	}
+	return e
+}
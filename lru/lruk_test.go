@@ -0,0 +1,91 @@
+package lru
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLRU2(t *testing.T) {
+	t.Run("once_first", func(t *testing.T) {
+		// 只访问过一次的节点先被驱逐，即使它更新
+		lru := newPolicyLRU(2, 1, PolicyLRU2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Get("key1")
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key2")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+		checkHeap(t, lru.lruK.heap)
+	})
+
+	t.Run("second_access", func(t *testing.T) {
+		// 都访问过两次时，倒数第二次访问更早的先被驱逐
+		lru := newPolicyLRU(2, 1, PolicyLRU2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key2")
+		lru.Get("key1")
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Get("key3")
+		_, ok, _ := lru.Get("key1")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key2")
+		assert.True(t, ok)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		// 被驱逐后很快回来的key带着历史访问时间，视为热点
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc:     HashXXHASH,
+			Policy:       PolicyLRU2,
+			HistoryRatio: 1,
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0) // key1被驱逐
+		assert.Equal(t, uint32(1), lru.lruK.history.Len())
+		lru.Add("key1", []byte("val1"), 0)                 // key2被驱逐
+		assert.Equal(t, uint32(1), lru.lruK.history.Len()) // key1离开历史表，key2进入
+		e := findEntry(lru, "key1")
+		assert.Equal(t, uint64(1), lru.lruK.prev[e.Idx()])
+		lru.Add("key4", []byte("val4"), 0)
+		_, ok, _ := lru.Get("key3")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		// 优先级是第一排序键，最高优先级不会被驱逐
+		lru := newPolicyLRU(2, 2, PolicyLRU2)
+		lru.Add("pinned", []byte("p"), 2)
+		lru.Add("low", []byte("l"), 0)
+		lru.Get("low")
+		lru.Add("mid", []byte("m"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("pinned")
+		assert.True(t, ok)
+		checkHeap(t, lru.lruK.heap)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		// 主动删除不进入历史表
+		lru := newPolicyLRU(2, 1, PolicyLRU2)
+		lru.Add("key1", []byte("val1"), 0)
+		_, ok, _ := lru.Remove("key1")
+		assert.True(t, ok)
+		assert.Equal(t, 0, lru.lruK.heap.Len())
+		assert.Equal(t, uint32(0), lru.lruK.history.Len())
+	})
+}
+
+func TestLRU2_HitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 5000, 1000, 500)
+	lruRatio := hitRatio(newPolicyLRU(500, 1, PolicyLRU), trace)
+	lru2Ratio := hitRatio(newPolicyLRU(500, 1, PolicyLRU2), trace)
+	t.Logf("lru: %.4f lru-2: %.4f", lruRatio, lru2Ratio)
+	assert.True(t, lru2Ratio >= lruRatio)
+}
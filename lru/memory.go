@@ -0,0 +1,125 @@
+package lru
+
+import (
+	"unsafe"
+
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+// Sizer may be implemented by keys and values to report the bytes they hold
+// besides the entry, it is used when Options.SizeFunc is nil.
+type Sizer interface {
+	Size() uint64
+}
+
+// MemoryStats is a snapshot of the memory used by a cache.
+type MemoryStats struct {
+	Arena   uint64 // the allocated chunks of the list.Entry arena, including their unused entries
+	Buckets uint64 // the hash buckets
+	FreeIdx uint64 // the free index stack of the arena
+	Policy  uint64 // the state of the policy, read buffers, admission filter and entry sizes, maps are not counted
+	Payload uint64 // the bytes reported by Options.SizeFunc or Sizer for the entries in the cache
+}
+
+// Fixed returns the overhead which does not depend on the entries.
+func (m MemoryStats) Fixed() uint64 {
+	return m.Arena + m.Buckets + m.FreeIdx + m.Policy
+}
+
+func (m MemoryStats) Total() uint64 {
+	return m.Fixed() + m.Payload
+}
+
+// sizerFunc returns a size function built on the Sizer of K and V, nil if
+// neither implements it. Which of them does is decided once here.
+func sizerFunc[K any, V any]() func(*K, *V) uint64 {
+	keySize := sizerOf[K]()
+	valueSize := sizerOf[V]()
+	switch {
+	case keySize == nil && valueSize == nil:
+		return nil
+	case valueSize == nil:
+		return func(k *K, v *V) uint64 { return keySize(k) }
+	case keySize == nil:
+		return func(k *K, v *V) uint64 { return valueSize(v) }
+	}
+	return func(k *K, v *V) uint64 {
+		return keySize(k) + valueSize(v)
+	}
+}
+
+// sizerOf returns the Size of a T, nil if T does not implement Sizer. The T
+// is read through a pointer into the entry, so it is not copied into an
+// interface on every call.
+func sizerOf[T any]() func(*T) uint64 {
+	if _, ok := any((*T)(nil)).(Sizer); ok {
+		return func(t *T) uint64 { return any(t).(Sizer).Size() }
+	}
+	if _, ok := any(*new(T)).(Sizer); ok {
+		// T is a pointer with a pointer receiver
+		return func(t *T) uint64 { return any(*t).(Sizer).Size() }
+	}
+	return nil
+}
+
+// payloadAdd counts the payload of e, after it was inserted or its value
+// changed. The size is kept, so it is uncounted the same even if the key or
+// the value reports another one later.
+func (lru *LRU[K, V]) payloadAdd(e *jlist.Entry[K, V]) {
+	if lru.sizeFunc != nil {
+		size := lru.sizeFunc(&e.Key, &e.Value)
+		lru.sizes[e.Idx()] = size
+		lru.payload += size
+	}
+}
+
+// payloadSub uncounts the payload of e, before it is removed or its value changes.
+func (lru *LRU[K, V]) payloadSub(e *jlist.Entry[K, V]) {
+	if lru.sizeFunc != nil {
+		lru.payload -= lru.sizes[e.Idx()]
+	}
+}
+
+// MemoryUsage reports the fixed overhead of the cache separately from the
+// payload of its entries.
+func (lru *LRU[K, V]) MemoryUsage() MemoryStats {
+	lru.RLock()
+	defer lru.RUnlock()
+	var m MemoryStats
+	if lru.closed {
+		return m
+	}
+	m.Arena, m.FreeIdx = lru.ll.MemoryUsage()
+	m.Buckets = uint64(cap(lru.buckets)) * uint64(unsafe.Sizeof(uint32(0)))
+	m.Payload = lru.payload
+	if lru.readBufs != nil {
+		m.Policy += uint64(len(lru.readBufs)) * uint64(unsafe.Sizeof(readBuffer{}))
+	}
+	if lru.sketch != nil {
+		m.Policy += uint64(len(lru.sketch.table)+len(lru.sketch.doorkeeper)) * 8
+	}
+	if lru.arc != nil {
+		m.Policy += lru.arc.b1.memoryUsage() + lru.arc.b2.memoryUsage()
+	}
+	m.Policy += uint64(cap(lru.sizes)) * 8
+	if lru.refs != nil {
+		m.Policy += uint64(cap(lru.refs)) * 4
+	}
+	m.Policy += uint64(cap(lru.segs)) + uint64(cap(lru.freqs))*4
+	if lru.gdsf != nil {
+		m.Policy += lru.gdsf.heap.memoryUsage() + uint64(cap(lru.gdsf.score))*8
+	}
+	if lru.lruK != nil {
+		m.Policy += lru.lruK.heap.memoryUsage() + lru.lruK.history.memoryUsage() + uint64(cap(lru.lruK.last)+cap(lru.lruK.prev))*8
+	}
+	return m
+}
+
+func (h *idxHeap) memoryUsage() uint64 {
+	return uint64(cap(h.items)+cap(h.pos)) * 4
+}
+
+func (g *ghostList) memoryUsage() uint64 {
+	arena, freeIdx := g.ll.MemoryUsage()
+	return arena + freeIdx
+}
@@ -0,0 +1,149 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"unsafe"
+)
+
+type blob []byte
+
+func (b blob) Size() uint64 {
+	return uint64(len(b))
+}
+
+// resizable 值的大小在写入后可能改变
+type resizable struct {
+	size uint64
+}
+
+func (r *resizable) Size() uint64 {
+	return r.size
+}
+
+func TestMemoryUsage(t *testing.T) {
+	t.Run("fixed", func(t *testing.T) {
+		// arena按分块分配，同一分块内写入不增加固定开销
+		lru, _ := NewPriorityLRU[string, []byte](100, 2, HashXXHASH, nil)
+		m := lru.MemoryUsage()
+		entrySize := uint64(unsafe.Sizeof(jlist.Entry[string, []byte]{}))
+		assert.Equal(t, uint64(128)*entrySize, m.Arena)
+		assert.Equal(t, uint64(100*4), m.Buckets)
+		assert.Equal(t, uint64(0), m.FreeIdx)
+		assert.Equal(t, uint64(0), m.Policy)
+		assert.Equal(t, uint64(0), m.Payload)
+		lru.Add("key1", []byte("val1"), 0)
+		assert.Equal(t, m, lru.MemoryUsage())
+		assert.Equal(t, m.Fixed(), m.Total())
+	})
+
+	t.Run("size_func", func(t *testing.T) {
+		// 写入、更新、删除、驱逐时更新payload
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			SizeFunc: func(key string, value []byte) uint64 {
+				return uint64(len(key) + len(value))
+			},
+		})
+		lru.Add("key1", []byte("val1"), 0)
+		assert.Equal(t, uint64(8), lru.MemoryUsage().Payload)
+		lru.Add("key1", []byte("value1"), 0)
+		assert.Equal(t, uint64(10), lru.MemoryUsage().Payload)
+		lru.AddToBack("key1", []byte("v"), 0)
+		assert.Equal(t, uint64(5), lru.MemoryUsage().Payload)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0) // 驱逐key1
+		assert.Equal(t, uint64(16), lru.MemoryUsage().Payload)
+		lru.Remove("key2")
+		m := lru.MemoryUsage()
+		assert.Equal(t, uint64(8), m.Payload)
+		assert.Equal(t, m.Fixed()+8, m.Total())
+	})
+
+	t.Run("sizer", func(t *testing.T) {
+		// 未指定SizeFunc时使用value的Sizer
+		lru, _ := NewPriorityLRU[string, blob](10, 1, HashXXHASH, nil)
+		lru.Add("key1", make(blob, 100), 0)
+		lru.Add("key2", make(blob, 28), 0)
+		assert.Equal(t, uint64(128), lru.MemoryUsage().Payload)
+	})
+
+	t.Run("size_kept", func(t *testing.T) {
+		// 删除时减去写入时记录的大小，值的大小变化不会使payload漂移
+		lru, _ := NewPriorityLRU[string, *resizable](2, 1, HashXXHASH, nil)
+		r := &resizable{size: 10}
+		lru.Add("key1", r, 0)
+		lru.Add("key2", &resizable{size: 5}, 0)
+		assert.Equal(t, uint64(15), lru.MemoryUsage().Payload)
+		r.size = 100
+		lru.Remove("key1")
+		assert.Equal(t, uint64(5), lru.MemoryUsage().Payload)
+		r.size = 1
+		lru.Add("key2", r, 0)
+		lru.Add("key3", &resizable{size: 2}, 0)
+		lru.Add("key4", &resizable{size: 3}, 0) // 驱逐key2
+		assert.Equal(t, uint64(5), lru.MemoryUsage().Payload)
+	})
+
+	t.Run("sizer_no_alloc", func(t *testing.T) {
+		// Sizer不把值复制到接口中
+		lru, _ := NewPriorityLRU[int, blob](10, 1, nil, nil)
+		value := make(blob, 100)
+		lru.Add(1, value, 0)
+		allocs := testing.AllocsPerRun(100, func() {
+			lru.Add(1, value, 0)
+		})
+		assert.Equal(t, float64(0), allocs)
+		assert.Equal(t, uint64(100), lru.MemoryUsage().Payload)
+	})
+
+	t.Run("side_lazy", func(t *testing.T) {
+		// 策略的附加数组随arena分块增长，空缓存只占用第一个分块
+		lru, _ := NewPriorityLRUWithOptions[int, int](1<<20, 1, Options[int, int]{Policy: PolicyClock})
+		assert.Equal(t, uint64(4096*4), lru.MemoryUsage().Policy)
+		for i := 0; i < 5000; i++ {
+			lru.Add(i, i, 0)
+		}
+		assert.Equal(t, uint64(8192*4), lru.MemoryUsage().Policy)
+		for i := 0; i < 5000; i++ {
+			_, ok, _ := lru.Get(i)
+			assert.True(t, ok)
+		}
+
+		// gdsf的频率、得分和堆的位置
+		lru, _ = NewPriorityLRUWithOptions[int, int](1<<20, 1, Options[int, int]{Policy: PolicyGDSF})
+		assert.Equal(t, uint64(4096*(4+8+4)), lru.MemoryUsage().Policy)
+		for i := 0; i < 5000; i++ {
+			lru.Add(i, i, 0)
+		}
+		for i := 0; i < 5000; i++ {
+			_, ok, _ := lru.Get(i)
+			assert.True(t, ok)
+		}
+
+		// lru-2的访问时间和堆的位置
+		lru, _ = NewPriorityLRUWithOptions[int, int](1<<20, 1, Options[int, int]{Policy: PolicyLRU2})
+		assert.Equal(t, uint64(4096*(8+8+4)), lru.MemoryUsage().Policy)
+		for i := 0; i < 5000; i++ {
+			lru.Add(i, i, 0)
+		}
+		for i := 0; i < 5000; i++ {
+			_, ok, _ := lru.Get(i)
+			assert.True(t, ok)
+		}
+		lru.Add(5000, 5000, 0)
+		_, ok, _ := lru.Get(5000)
+		assert.True(t, ok)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		// 策略的附加结构计入Policy
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](100, 1, Options[string, []byte]{
+			HashFunc: HashXXHASH,
+			Policy:   PolicyGDSF,
+			TinyLFU:  true,
+		})
+		assert.True(t, lru.MemoryUsage().Policy > 0)
+	})
+}
@@ -0,0 +1,67 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+	"sync/atomic"
+)
+
+const readBufferStripes = 16
+const readBufferSize = 64
+
+// readBuffer is a lossy buffer of the entries hit by Get. Producers append
+// under the read lock and the buffer is drained under the write lock, so the
+// two never run at the same time. Once a buffer is full further hits are
+// dropped until the next drain.
+type readBuffer struct {
+	writes uint32
+	_      [60]byte
+	slots  [readBufferSize]uint64
+}
+
+// recordAccess records the hit entry e and reports whether its buffer got full.
+func (lru *LRU[K, V]) recordAccess(e *jlist.Entry[K, V]) bool {
+	rb := &lru.readBufs[e.HashId&(readBufferStripes-1)]
+	n := atomic.AddUint32(&rb.writes, 1) - 1
+	if n >= readBufferSize {
+		atomic.AddUint64(&lru.metrics.DroppedPromotions, 1)
+		return false
+	}
+	atomic.StoreUint64(&rb.slots[n], uint64(uint32(e.HashId))<<32|uint64(e.Idx()))
+	return n == readBufferSize-1
+}
+
+// drainReadBuffers applies the recorded hits to the lru order, must be called
+// with the write lock held. Slots whose entry was removed or reused since the
+// hit are skipped.
+func (lru *LRU[K, V]) drainReadBuffers() {
+	for i := range lru.readBufs {
+		rb := &lru.readBufs[i]
+		n := rb.writes
+		if n > readBufferSize {
+			n = readBufferSize
+		}
+		for _, slot := range rb.slots[:n] {
+			e, err := lru.ll.Entry(uint32(slot))
+			if err != nil || e.Flag != 0 || uint32(e.HashId) != uint32(slot>>32) {
+				continue
+			}
+			if lru.touch(e) != nil {
+				atomic.AddUint64(&lru.metrics.Errors, 1)
+			}
+		}
+		rb.writes = 0
+	}
+}
+
+// Sync applies all the buffered hits to the lru order.
+func (lru *LRU[K, V]) Sync() {
+	if lru.readBufs == nil {
+		return
+	}
+	lru.Lock()
+	defer lru.Unlock()
+	if lru.closed {
+		return
+	}
+	lru.drainReadBuffers()
+}
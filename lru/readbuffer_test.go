@@ -0,0 +1,104 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func newReadBufferLRU(capacity int) *LRU[string, []byte] {
+	lru, _ := NewPriorityLRUWithOptions[string, []byte](capacity, 1, Options[string, []byte]{
+		HashFunc:   HashXXHASH,
+		ReadBuffer: true,
+	})
+	return lru
+}
+
+func TestReadBuffer(t *testing.T) {
+	t.Run("sync_promotes", func(t *testing.T) {
+		// Sync后缓冲的访问生效，key1被提升到队列前
+		lru := newReadBufferLRU(2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		_, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		lru.Sync()
+		keys, _, _ := lru.Iterate()
+		assert.Equal(t, []string{"key1", "key2"}, keys)
+		assert.True(t, lru.RemoveOldest())
+		_, ok, _ = lru.Get("key2")
+		assert.False(t, ok)
+	})
+
+	t.Run("drain_on_add", func(t *testing.T) {
+		// 下一次Add时先应用缓冲的访问，再驱逐
+		lru := newReadBufferLRU(2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("key2")
+		assert.False(t, ok)
+	})
+
+	t.Run("dropped_promotions", func(t *testing.T) {
+		// 缓冲满且无法获取写锁时，后续的访问被丢弃
+		lru := newReadBufferLRU(2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.RLock()
+		for i := 0; i < readBufferSize+10; i++ {
+			_, ok, _ := lru.Get("key1")
+			assert.True(t, ok)
+		}
+		lru.RUnlock()
+		assert.Equal(t, uint64(10), lru.Metrics().DroppedPromotions)
+		lru.Sync()
+		assert.Equal(t, uint32(0), lru.readBufs[0].writes)
+	})
+
+	t.Run("stale_slot", func(t *testing.T) {
+		// 访问记录对应的节点已被删除时跳过
+		lru := newReadBufferLRU(2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Get("key1")
+		lru.Remove("key1")
+		lru.Sync()
+		assert.Equal(t, uint64(0), lru.Metrics().Errors)
+		assert.Equal(t, uint32(0), lru.Len())
+	})
+
+	t.Run("unsupported_policy", func(t *testing.T) {
+		lru, err := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc:   HashXXHASH,
+			Policy:     PolicyClock,
+			ReadBuffer: true,
+		})
+		assert.Error(t, err)
+		assert.Nil(t, lru)
+	})
+}
+
+func TestReadBuffer_ConcurrentAccess(t *testing.T) {
+	lru := newReadBufferLRU(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", idx%20)
+			lru.Add(key, []byte(key), 0)
+			for j := 0; j < 100; j++ {
+				lru.Get(key)
+			}
+			if idx%5 == 0 {
+				lru.Remove(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	lru.Sync()
+	assert.True(t, lru.Len() <= 10)
+}
@@ -0,0 +1,87 @@
+package lru
+
+import (
+	"hash/maphash"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxChainLen is the number of entries in one bucket above which the keys are
+// taken as flooding the hash, a fair hash keeps the chains far shorter.
+const maxChainLen = 32
+
+// seededHash hashes the keys with a random seed of the cache. When a lookup
+// finds a chain longer than maxChainLen the next write switches to a fresh seed
+// and rehashes the entries in place. The hashes kept by the policies, like the
+// ghost lists of arc or the tinylfu sketch, are not rehashed, they only lose
+// their history.
+type seededHash[K any] struct {
+	hash    func(maphash.Seed, K) uint64
+	seed    maphash.Seed
+	pending uint32
+}
+
+func newSeededHash[K any]() (*seededHash[K], error) {
+	s := &seededHash[K]{seed: maphash.MakeSeed()}
+	t := reflect.TypeOf((*K)(nil)).Elem()
+	switch {
+	case t.Kind() == reflect.String:
+		s.hash = func(seed maphash.Seed, k K) uint64 {
+			return maphash.String(seed, *(*string)(unsafe.Pointer(&k)))
+		}
+	case memHashable(t):
+		s.hash = func(seed maphash.Seed, k K) uint64 {
+			return maphash.Bytes(seed, unsafe.Slice((*byte)(unsafe.Pointer(&k)), unsafe.Sizeof(k)))
+		}
+	default:
+		return nil, ErrHashFuncRequired
+	}
+	return s, nil
+}
+
+// flooded marks the seed for a change, it may be called under the read lock.
+func (lru *LRU[K, V]) flooded() {
+	atomic.StoreUint32(&lru.seeded.pending, 1)
+}
+
+// maybeReseed switches to a fresh seed if a long chain was found, the write
+// lock must be held.
+func (lru *LRU[K, V]) maybeReseed() error {
+	if lru.seeded == nil || atomic.LoadUint32(&lru.seeded.pending) == 0 {
+		return nil
+	}
+	atomic.StoreUint32(&lru.seeded.pending, 0)
+	lru.seeded.seed = maphash.MakeSeed()
+	atomic.AddUint64(&lru.metrics.Reseeds, 1)
+	lru.rehashWatches()
+	return lru.rehash()
+}
+
+// rehash rebuilds the buckets with the current hash function.
+func (lru *LRU[K, V]) rehash() error {
+	for k := range lru.buckets {
+		lru.buckets[k] = emptyBucket
+	}
+	front := lru.ll.Front()
+	if front == nil {
+		return nil
+	}
+	e := front
+	for {
+		if e.Flag == 0 {
+			e.HashId = lru.hashFunc(e.Key)
+			if err := lru.addEntryInBuk(lru.getBucketPos(e.HashId), e); err != nil {
+				return err
+			}
+		}
+		next, err := lru.ll.Entry(e.Next())
+		if err != nil {
+			return err
+		}
+		if next == front {
+			return nil
+		}
+		e = next
+	}
+}
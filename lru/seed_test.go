@@ -0,0 +1,65 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/maphash"
+	"testing"
+)
+
+func TestSeededHash(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		// 种子哈希不能和HashFunc同时使用
+		_, err := NewPriorityLRUWithOptions[string, []byte](10, 1, Options[string, []byte]{
+			HashFunc:   HashXXHASH,
+			SeededHash: true,
+		})
+		assert.Error(t, err)
+		_, err = NewPriorityLRUWithOptions[*int, []byte](10, 1, Options[*int, []byte]{SeededHash: true})
+		assert.Equal(t, ErrHashFuncRequired, err)
+		a, _ := NewPriorityLRUWithOptions[string, []byte](10, 1, Options[string, []byte]{SeededHash: true})
+		b, _ := NewPriorityLRUWithOptions[string, []byte](10, 1, Options[string, []byte]{SeededHash: true})
+		assert.NotEqual(t, a.seeded.seed, b.seeded.seed) // 每个缓存使用独立的种子
+	})
+
+	t.Run("reseed_on_flooding", func(t *testing.T) {
+		// 链过长时更换种子并原地重新哈希，所有key仍可读取
+		lru, err := NewPriorityLRUWithOptions[string, []byte](256, 1, Options[string, []byte]{SeededHash: true})
+		assert.NoError(t, err)
+		hash := lru.seeded.hash
+		flooded := lru.seeded.seed
+		lru.seeded.hash = func(seed maphash.Seed, k string) uint64 {
+			if seed == flooded {
+				return 0 // 模拟攻击者构造的冲突
+			}
+			return hash(seed, k)
+		}
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, lru.Add(fmt.Sprintf("key_%d", i), []byte("val"), 0))
+		}
+		assert.Equal(t, uint64(1), lru.Metrics().Reseeds)
+		assert.NotEqual(t, flooded, lru.seeded.seed)
+		for i := 0; i < 100; i++ {
+			_, ok, err := lru.Get(fmt.Sprintf("key_%d", i))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.Equal(t, uint32(100), lru.Len())
+		var chained int
+		for _, idx := range lru.buckets {
+			if idx != emptyBucket {
+				chained++
+			}
+		}
+		assert.True(t, chained > 1)
+	})
+
+	t.Run("no_reseed", func(t *testing.T) {
+		// 正常分布的key不会触发更换种子
+		lru, _ := NewPriorityLRUWithOptions[uint64, []byte](1000, 1, Options[uint64, []byte]{SeededHash: true})
+		for i := uint64(0); i < 5000; i++ {
+			lru.Add(i, nil, 0)
+		}
+		assert.Equal(t, uint64(0), lru.Metrics().Reseeds)
+	})
+}
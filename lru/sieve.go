@@ -0,0 +1,126 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+const sieveNoHand = ^uint32(0)
+
+// In sieve mode a hit only sets the visited bit of the entry under the read
+// lock, the entries never move inside their band. Every band has a hand which
+// walks from the back to the front, clearing the visited bits, and stops at
+// the first entry that was not visited since. The hand wraps to the back when
+// it reaches the front. The lower bands are swept first.
+type sieveState struct {
+	hands []uint32
+}
+
+func newSieveState(maxPriority byte) *sieveState {
+	hands := make([]uint32, int(maxPriority)+1)
+	for i := range hands {
+		hands[i] = sieveNoHand
+	}
+	return &sieveState{hands: hands}
+}
+
+func (s *sieveState) reset() {
+	for i := range s.hands {
+		s.hands[i] = sieveNoHand
+	}
+}
+
+// sieveUnhand moves the hand of the band of e off e before e leaves its place.
+func (lru *LRU[K, V]) sieveUnhand(e *jlist.Entry[K, V]) {
+	if lru.sieve.hands[e.Priority] != e.Idx() {
+		return
+	}
+	lru.sieve.hands[e.Priority] = sieveNoHand
+	prev, err := lru.ll.Entry(e.Prev())
+	if err == nil && prev.Flag == 0 {
+		lru.sieve.hands[e.Priority] = prev.Idx()
+	}
+}
+
+func (lru *LRU[K, V]) sieveOldest() *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		markNode, err := lru.getPriorityMarkNode(i)
+		if err != nil {
+			return nil
+		}
+		idx := lru.sieve.hands[i]
+		if idx == sieveNoHand {
+			idx = markNode.Prev()
+		}
+		var wrapped bool
+		for {
+			e, err := lru.ll.Entry(idx)
+			if err != nil {
+				return nil
+			}
+			if e.Flag != 0 {
+				if wrapped {
+					break
+				}
+				wrapped = true
+				idx = markNode.Prev()
+				continue
+			}
+			if lru.refs[idx] == 0 {
+				lru.sieve.hands[i] = idx
+				return e
+			}
+			lru.refs[idx] = 0
+			idx = e.Prev()
+		}
+		lru.sieve.hands[i] = sieveNoHand
+	}
+	return nil
+}
+
+// sievePeek returns the entry sieveOldest would evict: the first one not
+// visited from the hand of the lowest band, or the one at the hand if all of
+// them were visited.
+func (lru *LRU[K, V]) sievePeek() *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		markNode, err := lru.getPriorityMarkNode(i)
+		if err != nil {
+			return nil
+		}
+		start := lru.sieve.hands[i]
+		if start == sieveNoHand {
+			start = markNode.Prev()
+		}
+		idx := start
+		var first *jlist.Entry[K, V]
+		var wrapped bool
+		for {
+			e, err := lru.ll.Entry(idx)
+			if err != nil {
+				return nil
+			}
+			if e.Flag != 0 {
+				if first == nil || wrapped {
+					break
+				}
+				wrapped = true
+				idx = markNode.Prev()
+				continue
+			}
+			if lru.refs[idx] == 0 {
+				return e
+			}
+			if first == nil {
+				first = e
+			} else if idx == start {
+				break
+			}
+			idx = e.Prev()
+		}
+		if first != nil {
+			return first
+		}
+	}
+	return nil
+}
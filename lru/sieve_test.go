@@ -0,0 +1,125 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestSIEVE(t *testing.T) {
+	t.Run("visited_survive", func(t *testing.T) {
+		// 被访问过的节点不移动位置，指针经过时清除访问位并跳过
+		lru := newPolicyLRU(3, 1, PolicySIEVE)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Get("key1")
+		lru.Add("key4", []byte("val4"), 0) // 驱逐key2
+		_, ok, _ := lru.Get("key2")
+		assert.False(t, ok)
+		e := findEntry(lru, "key1")
+		assert.Equal(t, uint32(0), lru.refs[e.Idx()]) // 指针经过时访问位被清除
+		e = findEntry(lru, "key3")
+		assert.Equal(t, e.Idx(), lru.sieve.hands[0]) // 指针停在被驱逐节点的前一个
+	})
+
+	t.Run("hand_keeps_position", func(t *testing.T) {
+		// 指针不回到队尾，从上次的位置继续扫描
+		lru := newPolicyLRU(3, 1, PolicySIEVE)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Get("key1")
+		lru.Add("key4", []byte("val4"), 0) // 驱逐key2
+		lru.Add("key5", []byte("val5"), 0) // 驱逐key3，不是刚被清除访问位的key1
+		_, ok, _ := lru.Get("key3")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+	})
+
+	t.Run("all_visited", func(t *testing.T) {
+		// 所有节点都被访问过时，绕回队尾驱逐最旧的节点
+		lru := newPolicyLRU(2, 1, PolicySIEVE)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Get("key2")
+		lru.Add("key3", []byte("val3"), 0)
+		_, ok, _ := lru.Get("key1")
+		assert.False(t, ok)
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("priority_band", func(t *testing.T) {
+		// 先扫描低优先级，更新优先级时节点移动到新的优先级
+		lru := newPolicyLRU(3, 2, PolicySIEVE)
+		lru.Add("high", []byte("h"), 1)
+		lru.Add("low", []byte("l"), 0)
+		lru.Add("up", []byte("u"), 0)
+		lru.Get("low")
+		lru.Add("up", []byte("u"), 1)
+		lru.Add("new", []byte("n"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("up")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("high")
+		assert.True(t, ok)
+	})
+
+	t.Run("remove_hand", func(t *testing.T) {
+		// 删除指针所在的节点时，指针前移
+		lru := newPolicyLRU(3, 1, PolicySIEVE)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Add("key3", []byte("val3"), 0)
+		lru.Add("key4", []byte("val4"), 0) // 驱逐key1，指针停在key2
+		e := findEntry(lru, "key2")
+		assert.Equal(t, e.Idx(), lru.sieve.hands[0])
+		lru.Remove("key2")
+		e = findEntry(lru, "key3")
+		assert.Equal(t, e.Idx(), lru.sieve.hands[0])
+		lru.Remove("key3")
+		lru.Remove("key4")
+		assert.Equal(t, sieveNoHand, lru.sieve.hands[0])
+	})
+
+	t.Run("read_buffer", func(t *testing.T) {
+		_, err := NewPriorityLRUWithOptions[string, []byte](2, 1, Options[string, []byte]{
+			HashFunc:   HashXXHASH,
+			Policy:     PolicySIEVE,
+			ReadBuffer: true,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestSIEVE_Parallel(t *testing.T) {
+	// 并发读写，配合-race检查访问位的读写
+	lru := newPolicyLRU(100, 2, PolicySIEVE)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				key := fmt.Sprintf("key_%d", (i*j)%300)
+				if _, ok, _ := lru.Get(key); !ok {
+					lru.Add(key, []byte(key), byte(j%3))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, uint32(100), lru.Len())
+}
+
+func TestSIEVE_HitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 5000, 1000, 500)
+	lruRatio := hitRatio(newPolicyLRU(500, 1, PolicyLRU), trace)
+	sieveRatio := hitRatio(newPolicyLRU(500, 1, PolicySIEVE), trace)
+	t.Logf("lru: %.4f sieve: %.4f", lruRatio, sieveRatio)
+	assert.True(t, sieveRatio >= lruRatio)
+}
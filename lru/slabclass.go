@@ -0,0 +1,161 @@
+package lru
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+)
+
+const defaultGrowthFactor = 1.25
+const defaultMinChunkSize = 48
+
+var ErrItemTooLarge = errors.New("ItemTooLarge")
+var ErrSlabClassFull = errors.New("SlabClassFull")
+
+// SlabClassStats reports the chunks of one size class of a BytesLRU.
+type SlabClassStats struct {
+	ChunkSize  uint32
+	Pages      int
+	UsedChunks uint64
+	FreeChunks uint64
+	Evictions  uint64
+}
+
+// slabClass hands out chunks of one size, the chunks of a page are carved at
+// once and kept in a free stack.
+type slabClass struct {
+	size      uint32
+	pages     int
+	chunks    uint64
+	free      []uint64 // page<<32 | offset
+	evictions uint64
+}
+
+// slabClasses stores every item, its key followed by its value, in a chunk of
+// the smallest class it fits, like memcached. The chunk sizes grow by the
+// growth factor up to the page size. Pages are given to the classes on demand
+// until maxPages, then a full class evicts its least recent item.
+type slabClasses struct {
+	classes   []slabClass
+	pageClass []uint8
+	pageSize  uint32
+	maxPages  int
+}
+
+func newSlabClasses(pageSize int, factor float64, minChunk int, maxMemory int64) (*slabClasses, error) {
+	if factor <= 1 {
+		factor = defaultGrowthFactor
+	}
+	if minChunk <= 0 {
+		minChunk = defaultMinChunkSize
+	}
+	if pageSize > 1<<31 || minChunk > pageSize {
+		return nil, errors.New("InvalidPageSize")
+	}
+	s := &slabClasses{pageSize: uint32(pageSize)}
+	if maxMemory > 0 {
+		s.maxPages = int(maxMemory / int64(pageSize))
+		if s.maxPages == 0 {
+			s.maxPages = 1
+		}
+	}
+	size := float64(minChunk)
+	for len(s.classes) < 255 {
+		chunk := (uint32(size) + 7) &^ 7
+		if chunk > s.pageSize/2 {
+			break
+		}
+		s.classes = append(s.classes, slabClass{size: chunk})
+		size *= factor
+		if uint32(size) <= chunk {
+			size = float64(chunk + 8)
+		}
+	}
+	s.classes = append(s.classes, slabClass{size: s.pageSize})
+	return s, nil
+}
+
+// classOf returns the class of an item of n bytes.
+func (s *slabClasses) classOf(n int) (int, error) {
+	c := sort.Search(len(s.classes), func(i int) bool {
+		return s.classes[i].size >= uint32(n)
+	})
+	if c == len(s.classes) {
+		return 0, ErrItemTooLarge
+	}
+	return c, nil
+}
+
+func (s *slabClasses) stats() []SlabClassStats {
+	stats := make([]SlabClassStats, len(s.classes))
+	for i, cl := range s.classes {
+		stats[i] = SlabClassStats{
+			ChunkSize:  cl.size,
+			Pages:      cl.pages,
+			UsedChunks: cl.chunks - uint64(len(cl.free)),
+			FreeChunks: uint64(len(cl.free)),
+			Evictions:  cl.evictions,
+		}
+	}
+	return stats
+}
+
+// allocChunk returns a free chunk of class c as page and offset, it may add a
+// page or evict the least recent item of the class.
+func (b *BytesLRU) allocChunk(c int) (uint32, uint32, error) {
+	s := b.classes
+	cl := &s.classes[c]
+	if len(cl.free) == 0 && (s.maxPages == 0 || len(b.slabs) < s.maxPages) {
+		page := uint64(len(b.slabs))
+		b.slabs = append(b.slabs, make([]byte, s.pageSize))
+		s.pageClass = append(s.pageClass, uint8(c))
+		b.total += uint64(s.pageSize)
+		cl.pages++
+		for off := s.pageSize / cl.size * cl.size; off >= cl.size; off -= cl.size {
+			cl.free = append(cl.free, page<<32|uint64(off-cl.size))
+			cl.chunks++
+		}
+	}
+	if len(cl.free) == 0 && !b.evictClass(c) {
+		return 0, 0, ErrSlabClassFull
+	}
+	chunk := cl.free[len(cl.free)-1]
+	cl.free = cl.free[:len(cl.free)-1]
+	return uint32(chunk >> 32), uint32(chunk), nil
+}
+
+// freeChunk returns the chunk of the item whose key is at k.
+func (b *BytesLRU) freeChunk(k bytesRef) {
+	cl := &b.classes.classes[b.classes.pageClass[k.slab]]
+	cl.free = append(cl.free, uint64(k.slab)<<32|uint64(k.off))
+}
+
+// evictClass evicts the least recent item of class c. The items are walked in
+// the eviction order of the lru, from the back of the lowest band on, the
+// highest band is never evicted.
+func (b *BytesLRU) evictClass(c int) bool {
+	lru := b.lru
+	lru.Lock()
+	defer lru.Unlock()
+	markNode, err := lru.getPriorityMarkNode(0)
+	if err != nil {
+		return false
+	}
+	last := lru.pos[lru.maxPriority]
+	idx := markNode.Prev()
+	for idx != last {
+		e, err := lru.ll.Entry(idx)
+		if err != nil {
+			return false
+		}
+		if e.Flag == 0 && int(b.classes.pageClass[e.Key.slab]) == c {
+			if lru.removeElement(e, true) != nil {
+				return false
+			}
+			atomic.AddUint64(&lru.metrics.Evictions, 1)
+			return true
+		}
+		idx = e.Prev()
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSlabClasses(t *testing.T) {
+	t.Run("sizes", func(t *testing.T) {
+		// chunk大小按增长因子递增，8字节对齐，最后一级为整页
+		s, err := newSlabClasses(1024, 2, 48, 0)
+		assert.NoError(t, err)
+		var sizes []uint32
+		for _, cl := range s.classes {
+			sizes = append(sizes, cl.size)
+		}
+		assert.Equal(t, []uint32{48, 96, 192, 384, 1024}, sizes)
+		c, _ := s.classOf(100)
+		assert.Equal(t, 2, c)
+		c, _ = s.classOf(48)
+		assert.Equal(t, 0, c)
+		_, err = s.classOf(1025)
+		assert.Equal(t, ErrItemTooLarge, err)
+	})
+
+	t.Run("add_get_remove", func(t *testing.T) {
+		// key和value写入同一个chunk，删除后chunk回到空闲列表
+		b, err := NewBytesLRU(10, 1, BytesOptions{PageSize: 1024, GrowthFactor: 2})
+		assert.NoError(t, err)
+		assert.NoError(t, b.Add("key1", []byte("val1"), 0))
+		got, ok, _ := b.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("val1"), got)
+		stats := b.ClassStats()
+		assert.Equal(t, SlabClassStats{ChunkSize: 48, Pages: 1, UsedChunks: 1, FreeChunks: 20}, stats[0])
+		assert.Equal(t, BytesStats{Slabs: 1, LiveBytes: 8, DeadBytes: 1016}, b.Stats())
+
+		assert.NoError(t, b.Add("key1", make([]byte, 60), 0)) // 更新后换到更大的class
+		got, _, _ = b.Get("key1")
+		assert.Equal(t, make([]byte, 60), got)
+		stats = b.ClassStats()
+		assert.Equal(t, uint64(0), stats[0].UsedChunks)
+		assert.Equal(t, uint64(1), stats[1].UsedChunks)
+
+		ok, err = b.Remove("key1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(0), b.ClassStats()[1].UsedChunks)
+		assert.Equal(t, uint64(0), b.Stats().LiveBytes)
+	})
+
+	t.Run("class_full", func(t *testing.T) {
+		// 内存用满后，class内部驱逐最久未使用的条目，其他class不受影响
+		var evicted []string
+		b, _ := NewBytesLRU(100, 1, BytesOptions{
+			PageSize:     256,
+			GrowthFactor: 2,
+			MinChunkSize: 64,
+			MaxMemory:    512,
+			OnEvicted: func(key, value []byte) {
+				evicted = append(evicted, string(key))
+			},
+		})
+		assert.NoError(t, b.Add("big", make([]byte, 100), 0)) // 占用128字节class的一页
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, b.Add(fmt.Sprintf("small_%d", i), []byte("v"), 0))
+		}
+		b.Get("small_0")
+		assert.NoError(t, b.Add("small_4", []byte("v"), 0)) // 驱逐small_1
+		assert.Equal(t, []string{"small_1"}, evicted)
+		_, ok, _ := b.Get("big")
+		assert.True(t, ok)
+		_, ok, _ = b.Get("small_0")
+		assert.True(t, ok)
+		stats := b.ClassStats()
+		assert.Equal(t, SlabClassStats{ChunkSize: 64, Pages: 1, UsedChunks: 4, FreeChunks: 0, Evictions: 1}, stats[0])
+		assert.Equal(t, uint64(1), stats[1].UsedChunks)
+		assert.Equal(t, uint64(1), b.Metrics().Evictions)
+
+		_, ok, _ = b.Get("small_1")
+		assert.False(t, ok)
+		err := b.Add("huge", make([]byte, 200), 0) // 256字节的class没有可用的页
+		assert.Error(t, err)
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		// 最高优先级的条目不会被驱逐
+		b, _ := NewBytesLRU(100, 1, BytesOptions{PageSize: 128, MinChunkSize: 64, MaxMemory: 128})
+		assert.NoError(t, b.Add("key1", []byte("v"), 1))
+		assert.NoError(t, b.Add("key2", []byte("v"), 1))
+		err := b.Add("key3", []byte("v"), 0)
+		assert.Error(t, err)
+		assert.Equal(t, uint32(2), b.Len())
+	})
+}
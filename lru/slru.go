@@ -0,0 +1,84 @@
+package lru
+
+import (
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+const defaultProtectedRatio = 0.8
+
+// slru keeps new entries in the probation segment at the back of their band,
+// a second hit promotes them to the protected segment at the front. When the
+// protected segment outgrows its share of the band, its oldest entries are
+// demoted to the front of probation again.
+type slruState struct {
+	ratio float64
+}
+
+func newSlruState(ratio float64) *slruState {
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultProtectedRatio
+	}
+	return &slruState{ratio: ratio}
+}
+
+// slruHit moves an entry of probation to protected.
+func (lru *LRU[K, V]) slruHit(e *jlist.Entry[K, V]) {
+	if lru.segs[e.Idx()] == segRecent {
+		lru.segDel(e.Priority, segRecent)
+		lru.segAdd(e.Priority, segFrequent)
+		lru.segs[e.Idx()] = segFrequent
+	}
+}
+
+// slruDemote moves the oldest protected entries of band priority to probation
+// until protected fits its share of the band.
+func (lru *LRU[K, V]) slruDemote(priority byte) error {
+	band := lru.bandSeg[priority]
+	limit := uint32(float64(band[segRecent]+band[segFrequent]) * lru.slru.ratio)
+	if limit == 0 {
+		limit = 1
+	}
+	segNode, err := lru.getSegmentMarkNode(priority)
+	if err != nil {
+		return err
+	}
+	for lru.bandSeg[priority][segFrequent] > limit {
+		e, err := lru.ll.Entry(segNode.Prev())
+		if err != nil {
+			return err
+		}
+		if e.Flag != 0 {
+			return nil
+		}
+		err = lru.ll.MoveAfter(e, segNode)
+		if err != nil {
+			return err
+		}
+		lru.segDel(priority, segFrequent)
+		lru.segAdd(priority, segRecent)
+		lru.segs[e.Idx()] = segRecent
+	}
+	return nil
+}
+
+// slruOldest returns the oldest probation entry of the lowest band, or its
+// oldest protected entry if probation is empty.
+func (lru *LRU[K, V]) slruOldest() *jlist.Entry[K, V] {
+	var i byte
+	for i = 0; i < lru.maxPriority; i++ {
+		for _, seg := range [2]byte{segRecent, segFrequent} {
+			markNode, err := lru.backMarkNode(i, seg)
+			if err != nil {
+				return nil
+			}
+			e, err := lru.ll.Entry(markNode.Prev())
+			if err != nil {
+				return nil
+			}
+			if e.Flag == 0 {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package lru
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSLRU(t *testing.T) {
+	t.Run("promote_on_second_hit", func(t *testing.T) {
+		// 新节点进入probation，再次访问后进入protected
+		lru := newPolicyLRU(4, 1, PolicySLRU)
+		lru.Add("key1", []byte("val1"), 0)
+		assert.Equal(t, [2]uint32{1, 0}, lru.bandSeg[0])
+		lru.Get("key1")
+		assert.Equal(t, [2]uint32{0, 1}, lru.bandSeg[0])
+		assert.Equal(t, uint32(1), lru.Len())
+		assert.Equal(t, uint32(4), lru.Cap())
+	})
+
+	t.Run("scan_resistance", func(t *testing.T) {
+		// 扫描数据只驱逐probation中的节点
+		lru := newPolicyLRU(5, 1, PolicySLRU)
+		for _, key := range []string{"hot1", "hot2", "key1", "key2", "key3"} {
+			lru.Add(key, []byte(key), 0)
+		}
+		lru.Get("hot1")
+		lru.Get("hot2")
+		for _, key := range []string{"scan1", "scan2", "scan3", "scan4", "scan5"} {
+			lru.Add(key, []byte(key), 0)
+		}
+		_, ok, _ := lru.Get("hot1")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("hot2")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("scan1")
+		assert.False(t, ok)
+	})
+
+	t.Run("demote_overflow", func(t *testing.T) {
+		// protected超过所占比例时，最旧的节点降级到probation
+		lru, _ := NewPriorityLRUWithOptions[string, []byte](4, 1, Options[string, []byte]{
+			HashFunc:       HashXXHASH,
+			Policy:         PolicySLRU,
+			ProtectedRatio: 0.5,
+		})
+		for _, key := range []string{"key1", "key2", "key3", "key4"} {
+			lru.Add(key, []byte(key), 0)
+		}
+		for _, key := range []string{"key1", "key2", "key3", "key4"} {
+			lru.Get(key)
+		}
+		assert.Equal(t, [2]uint32{2, 2}, lru.bandSeg[0])
+		lru.Add("key5", []byte("val5"), 0) // 驱逐probation中最旧的key1
+		_, ok, _ := lru.Get("key1")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key4")
+		assert.True(t, ok)
+	})
+
+	t.Run("priority_band", func(t *testing.T) {
+		// 优先级仍然是第一淘汰依据
+		lru := newPolicyLRU(2, 2, PolicySLRU)
+		lru.Add("low", []byte("l"), 0)
+		lru.Get("low")
+		lru.Add("high", []byte("h"), 1)
+		lru.Add("new", []byte("n"), 1)
+		_, ok, _ := lru.Get("low")
+		assert.False(t, ok)
+		assert.Equal(t, [2]uint32{0, 0}, lru.bandSeg[0])
+	})
+
+	t.Run("change_priority", func(t *testing.T) {
+		lru := newPolicyLRU(2, 2, PolicySLRU)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key1", []byte("val1"), 1)
+		assert.Equal(t, [2]uint32{0, 0}, lru.bandSeg[0])
+		assert.Equal(t, [2]uint32{0, 1}, lru.bandSeg[1])
+		lru.Remove("key1")
+		assert.Equal(t, [2]uint32{0, 0}, lru.bandSeg[1])
+	})
+}
+
+func TestSLRU_HitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 5000, 1000, 500)
+	lruRatio := hitRatio(newPolicyLRU(500, 1, PolicyLRU), trace)
+	slruRatio := hitRatio(newPolicyLRU(500, 1, PolicySLRU), trace)
+	t.Logf("lru: %.4f slru: %.4f", lruRatio, slruRatio)
+	assert.True(t, slruRatio >= lruRatio)
+}
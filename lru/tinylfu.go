@@ -0,0 +1,149 @@
+package lru
+
+import (
+	"sync/atomic"
+)
+
+const tinyLFURows = 4
+const tinyLFUMaxCount = 15
+
+var tinyLFUSeeds = [tinyLFURows]uint64{0x9e3779b97f4a7c15, 0xbf58476d1ce4e5b9, 0x94d049bb133111eb, 0xff51afd7ed558ccd}
+
+// tinyLFU estimates how often a hash was seen recently. A doorkeeper bloom
+// filter absorbs the first access of every hash, the later ones are counted by
+// a count-min sketch of 4-bit counters. Every sampleSize increments the
+// counters are halved and the doorkeeper is cleared, so old popularity fades.
+// Counters are updated with atomics, so Get may record under the read lock.
+type tinyLFU struct {
+	table      []uint64 // 16 counters per word, tinyLFURows rows of width counters
+	width      uint32
+	doorkeeper []uint64
+	additions  uint32
+	sampleSize uint32
+}
+
+func newTinyLFU(capacity int) *tinyLFU {
+	width := uint32(64)
+	for width < uint32(capacity) {
+		width <<= 1
+	}
+	return &tinyLFU{
+		table:      make([]uint64, width*tinyLFURows/16),
+		width:      width,
+		doorkeeper: make([]uint64, width/8),
+		sampleSize: 10 * width,
+	}
+}
+
+// reset forgets all the counts.
+func (t *tinyLFU) reset() {
+	for i := range t.table {
+		t.table[i] = 0
+	}
+	for i := range t.doorkeeper {
+		t.doorkeeper[i] = 0
+	}
+	t.additions = 0
+}
+
+func (t *tinyLFU) counterPos(hashId uint64, row int) uint32 {
+	h := (hashId + 1) * tinyLFUSeeds[row]
+	h ^= h >> 32
+	return uint32(row)*t.width + uint32(h)&(t.width-1)
+}
+
+func (t *tinyLFU) doorkeeperBits(hashId uint64) (uint32, uint32) {
+	h := hashId * tinyLFUSeeds[0]
+	bits := uint32(len(t.doorkeeper) * 64)
+	return uint32(h) & (bits - 1), uint32(h>>32) & (bits - 1)
+}
+
+func (t *tinyLFU) testAndSetDoorkeeper(hashId uint64) bool {
+	b1, b2 := t.doorkeeperBits(hashId)
+	seen := true
+	for _, b := range [2]uint32{b1, b2} {
+		word := &t.doorkeeper[b>>6]
+		mask := uint64(1) << (b & 63)
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 {
+				break
+			}
+			seen = false
+			if atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+	return seen
+}
+
+func (t *tinyLFU) inDoorkeeper(hashId uint64) bool {
+	b1, b2 := t.doorkeeperBits(hashId)
+	return atomic.LoadUint64(&t.doorkeeper[b1>>6])&(1<<(b1&63)) != 0 &&
+		atomic.LoadUint64(&t.doorkeeper[b2>>6])&(1<<(b2&63)) != 0
+}
+
+// increment records an access of hashId.
+func (t *tinyLFU) increment(hashId uint64) {
+	if !t.testAndSetDoorkeeper(hashId) {
+		return
+	}
+	for row := 0; row < tinyLFURows; row++ {
+		pos := t.counterPos(hashId, row)
+		word := &t.table[pos>>4]
+		shift := (pos & 15) * 4
+		for {
+			old := atomic.LoadUint64(word)
+			if (old>>shift)&0xf == tinyLFUMaxCount {
+				break
+			}
+			if atomic.CompareAndSwapUint64(word, old, old+(1<<shift)) {
+				break
+			}
+		}
+	}
+	atomic.AddUint32(&t.additions, 1)
+}
+
+// estimate returns the recent access frequency of hashId.
+func (t *tinyLFU) estimate(hashId uint64) uint32 {
+	min := uint32(tinyLFUMaxCount)
+	for row := 0; row < tinyLFURows; row++ {
+		pos := t.counterPos(hashId, row)
+		count := uint32(atomic.LoadUint64(&t.table[pos>>4])>>((pos&15)*4)) & 0xf
+		if count < min {
+			min = count
+		}
+	}
+	if t.inDoorkeeper(hashId) {
+		min++
+	}
+	return min
+}
+
+// age halves all the counters and clears the doorkeeper once enough accesses
+// were recorded, must be called with the write lock held.
+func (t *tinyLFU) age() {
+	if atomic.LoadUint32(&t.additions) < t.sampleSize {
+		return
+	}
+	for i := range t.table {
+		t.table[i] = (t.table[i] >> 1) & 0x7777777777777777
+	}
+	for i := range t.doorkeeper {
+		t.doorkeeper[i] = 0
+	}
+	atomic.StoreUint32(&t.additions, 0)
+}
+
+// admit reports whether a new entry should replace the entry the cache would
+// evict for it. A higher priority always wins, otherwise the candidate must
+// have been seen more often than the victim.
+func (lru *LRU[K, V]) admit(hashId uint64, priority byte) bool {
+	victim := lru.peekOldest()
+	if victim == nil || priority > victim.Priority {
+		return true
+	}
+	return lru.sketch.estimate(hashId) > lru.sketch.estimate(victim.HashId)
+}
@@ -0,0 +1,143 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTinyLFULRU(capacity int, maxPriority byte) *LRU[string, []byte] {
+	lru, _ := NewPriorityLRUWithOptions[string, []byte](capacity, maxPriority, Options[string, []byte]{
+		HashFunc: HashXXHASH,
+		TinyLFU:  true,
+	})
+	return lru
+}
+
+func TestTinyLFU(t *testing.T) {
+	t.Run("scan_rejected", func(t *testing.T) {
+		// 只访问一次的扫描数据不能挤出热点数据
+		lru := newTinyLFULRU(100, 1)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("hot%d", i)
+			assert.NoError(t, lru.Add(key, []byte(key), 0))
+			for j := 0; j < 3; j++ {
+				lru.Get(key)
+			}
+		}
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("scan%d", i)
+			assert.Equal(t, ErrRejected, lru.Add(key, []byte(key), 0))
+		}
+		assert.Equal(t, uint64(200), lru.Metrics().Rejections)
+		assert.Equal(t, uint64(0), lru.Metrics().Evictions)
+		for i := 0; i < 100; i++ {
+			_, ok, _ := lru.Get(fmt.Sprintf("hot%d", i))
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("frequent_admitted", func(t *testing.T) {
+		// 访问频率高于被驱逐节点的新key可以写入
+		lru := newTinyLFULRU(2, 1)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		for i := 0; i < 5; i++ {
+			lru.Get("key3")
+		}
+		assert.NoError(t, lru.Add("key3", []byte("val3"), 0))
+		_, ok, _ := lru.Get("key3")
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1), lru.Metrics().Evictions)
+	})
+
+	t.Run("priority_admitted", func(t *testing.T) {
+		// 优先级高于被驱逐节点时总是写入
+		lru := newTinyLFULRU(2, 2)
+		lru.Add("key1", []byte("val1"), 0)
+		lru.Add("key2", []byte("val2"), 0)
+		lru.Get("key1")
+		lru.Get("key2")
+		assert.NoError(t, lru.Add("key3", []byte("val3"), 1))
+		assert.Equal(t, uint64(0), lru.Metrics().Rejections)
+	})
+
+	t.Run("update_not_filtered", func(t *testing.T) {
+		// 更新已存在的key不经过准入过滤
+		lru := newTinyLFULRU(1, 1)
+		lru.Add("key1", []byte("val1"), 0)
+		assert.NoError(t, lru.Add("key1", []byte("val2"), 0))
+		val, _, _ := lru.Get("key1")
+		assert.Equal(t, []byte("val2"), val)
+	})
+}
+
+func TestTinyLFU_Peek(t *testing.T) {
+	for _, policy := range []Policy{PolicyClock, PolicySIEVE} {
+		t.Run(fmt.Sprintf("rejected_keeps_refs_%d", policy), func(t *testing.T) {
+			// 被拒绝的写入不清除热点数据的访问位
+			lru, _ := NewPriorityLRUWithOptions[string, []byte](3, 1, Options[string, []byte]{
+				HashFunc: HashXXHASH,
+				TinyLFU:  true,
+				Policy:   policy,
+			})
+			for i := 0; i < 3; i++ {
+				key := fmt.Sprintf("hot%d", i)
+				lru.Add(key, []byte(key), 0)
+				lru.Get(key)
+				lru.Get(key)
+			}
+			for i := 0; i < 10; i++ {
+				assert.Equal(t, ErrRejected, lru.Add(fmt.Sprintf("scan%d", i), nil, 0))
+			}
+			for i := 0; i < 3; i++ {
+				e := findEntry(lru, fmt.Sprintf("hot%d", i))
+				assert.Equal(t, uint32(1), lru.refs[e.Idx()])
+			}
+		})
+
+		t.Run(fmt.Sprintf("same_victim_%d", policy), func(t *testing.T) {
+			// peekOldest与oldest选出同一个节点
+			lru := newPolicyLRU(8, 2, policy)
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("key%d", i%13)
+				if i%3 == 0 {
+					lru.Get(key)
+				} else {
+					lru.Add(key, []byte(key), byte(i%2))
+				}
+				lru.Lock()
+				peek := lru.peekOldest()
+				victim := lru.oldest()
+				lru.Unlock()
+				assert.Equal(t, victim, peek)
+			}
+		})
+	}
+}
+
+func TestTinyLFU_Sketch(t *testing.T) {
+	t.Run("estimate", func(t *testing.T) {
+		sketch := newTinyLFU(100)
+		assert.Equal(t, uint32(0), sketch.estimate(1))
+		sketch.increment(1)
+		assert.Equal(t, uint32(1), sketch.estimate(1)) // 第一次访问只进入doorkeeper
+		for i := 0; i < 30; i++ {
+			sketch.increment(1)
+		}
+		assert.Equal(t, uint32(tinyLFUMaxCount+1), sketch.estimate(1)) // 计数饱和
+	})
+
+	t.Run("age", func(t *testing.T) {
+		// 累计访问次数达到采样大小后计数减半，doorkeeper清空
+		sketch := newTinyLFU(16)
+		for i := 0; i < 9; i++ {
+			sketch.increment(7)
+		}
+		assert.Equal(t, uint32(9), sketch.estimate(7))
+		sketch.additions = sketch.sampleSize
+		sketch.age()
+		assert.Equal(t, uint32(4), sketch.estimate(7))
+		assert.Equal(t, uint32(0), sketch.additions)
+	})
+}
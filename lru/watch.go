@@ -0,0 +1,117 @@
+package lru
+
+import (
+	"sync"
+)
+
+const watchBuffer = 16
+
+// WatchEvent is a change of a watched key, Op is one of EventAdd,
+// EventUpdate, EventRemove and EventEvict.
+type WatchEvent[V any] struct {
+	Op       EventOp
+	Value    V
+	Priority byte
+}
+
+type watcher[K any, V any] struct {
+	key K
+	ch  chan WatchEvent[V]
+}
+
+// send never blocks, if the buffer is full the oldest event is dropped so the
+// last one always tells the current state of the key.
+func (w *watcher[K, V]) send(ev WatchEvent[V]) {
+	for {
+		select {
+		case w.ch <- ev:
+			return
+		default:
+		}
+		select {
+		case <-w.ch:
+		default:
+		}
+	}
+}
+
+// Watch returns the changes of key: inserted, updated, removed or evicted.
+// The watches are kept by the hash of their key, so the other keys do not pay
+// for them. cancel closes the channel, so does Close of the cache.
+func (lru *LRU[K, V]) Watch(key K) (<-chan WatchEvent[V], func()) {
+	if lru.cloneKey != nil {
+		// the caller may reuse key, the watch keeps a copy like the entries
+		key = lru.cloneKey(key)
+	}
+	w := &watcher[K, V]{key: key, ch: make(chan WatchEvent[V], watchBuffer)}
+	hashId, _ := lru.lockKey(key)
+	defer lru.Unlock()
+	if lru.closed {
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	if lru.watches == nil {
+		lru.watches = make(map[uint64][]*watcher[K, V])
+	}
+	lru.watches[hashId] = append(lru.watches[hashId], w)
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			lru.Lock()
+			defer lru.Unlock()
+			lru.unwatch(w)
+		})
+	}
+}
+
+func (lru *LRU[K, V]) unwatch(w *watcher[K, V]) {
+	hashId := lru.hashFunc(w.key)
+	watchers := lru.watches[hashId]
+	for i, watcher := range watchers {
+		if watcher == w {
+			watchers = append(watchers[:i], watchers[i+1:]...)
+			if len(watchers) == 0 {
+				delete(lru.watches, hashId)
+			} else {
+				lru.watches[hashId] = watchers
+			}
+			close(w.ch)
+			return
+		}
+	}
+}
+
+// notifyWatchers sends a change to the watchers of key, must be called with
+// the write lock held.
+func (lru *LRU[K, V]) notifyWatchers(op EventOp, hashId uint64, key K, value V, priority byte) {
+	for _, w := range lru.watches[hashId] {
+		if lru.equal(w.key, key) {
+			w.send(WatchEvent[V]{Op: op, Value: value, Priority: priority})
+		}
+	}
+}
+
+// rehashWatches files the watches under the new hashes after a reseed.
+func (lru *LRU[K, V]) rehashWatches() {
+	if len(lru.watches) == 0 {
+		return
+	}
+	watches := make(map[uint64][]*watcher[K, V], len(lru.watches))
+	for _, watchers := range lru.watches {
+		for _, w := range watchers {
+			hashId := lru.hashFunc(w.key)
+			watches[hashId] = append(watches[hashId], w)
+		}
+	}
+	lru.watches = watches
+}
+
+// closeWatches ends the watches of a closed cache.
+func (lru *LRU[K, V]) closeWatches() {
+	for _, watchers := range lru.watches {
+		for _, w := range watchers {
+			close(w.ch)
+		}
+	}
+	lru.watches = nil
+}
@@ -0,0 +1,119 @@
+package lru
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("ops", func(t *testing.T) {
+		// 只通知被watch的key的变更
+		lru, _ := NewPriorityLRU[string, string](2, 2, nil, nil)
+		ch, cancel := lru.Watch("key1")
+		defer cancel()
+		lru.Add("key1", "val1", 1)
+		lru.Add("key2", "val2", 0)
+		lru.Add("key1", "val3", 0)
+		lru.Remove("key1")
+		lru.AddToBack("key1", "val4", 0)
+		lru.Add("key3", "val5", 0) // 驱逐key1
+		assert.NoError(t, lru.Purge())
+		assert.Equal(t, []WatchEvent[string]{
+			{Op: EventAdd, Value: "val1", Priority: 1},
+			{Op: EventUpdate, Value: "val3", Priority: 0},
+			{Op: EventRemove, Value: "val3", Priority: 0},
+			{Op: EventAdd, Value: "val4", Priority: 0},
+			{Op: EventEvict, Value: "val4", Priority: 0},
+		}, drainWatch(ch))
+	})
+
+	t.Run("same_hash", func(t *testing.T) {
+		// 哈希相同的其它key不会通知
+		lru, _ := NewPriorityLRU[string, string](4, 1, func(string) uint32 { return 1 }, nil)
+		ch, cancel := lru.Watch("key1")
+		defer cancel()
+		lru.Add("key2", "val2", 0)
+		lru.Add("key1", "val1", 0)
+		assert.Equal(t, []WatchEvent[string]{{Op: EventAdd, Value: "val1"}}, drainWatch(ch))
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		// 缓冲区满时丢弃最旧的事件，最后一个事件总是当前状态
+		lru, _ := NewPriorityLRU[int, int](2, 1, nil, nil)
+		ch, cancel := lru.Watch(1)
+		defer cancel()
+		for i := 0; i < 100; i++ {
+			lru.Add(1, i, 0)
+		}
+		lru.Remove(1)
+		events := drainWatch(ch)
+		assert.Len(t, events, watchBuffer)
+		assert.Equal(t, WatchEvent[int]{Op: EventRemove, Value: 99}, events[len(events)-1])
+	})
+
+	t.Run("reseed", func(t *testing.T) {
+		// 更换种子后watch按新哈希继续生效
+		lru, _ := NewPriorityLRUWithOptions[string, string](4, 1, Options[string, string]{SeededHash: true})
+		ch, cancel := lru.Watch("key1")
+		defer cancel()
+		lru.flooded()
+		lru.Add("key1", "val1", 0)
+		assert.Equal(t, uint64(1), lru.Metrics().Reseeds)
+		assert.Equal(t, []WatchEvent[string]{{Op: EventAdd, Value: "val1"}}, drainWatch(ch))
+		cancel()
+		assert.Empty(t, lru.watches)
+	})
+
+	t.Run("cloned_key", func(t *testing.T) {
+		// 调用方复用key的缓冲区后，watch仍按原来的key通知和取消
+		lru, _ := NewPriorityLRUWithHasher[[]byte, string](10, 1, BytesHasher{}, Options[[]byte, string]{})
+		buf := []byte("key1")
+		ch, cancel := lru.Watch(buf)
+		copy(buf, "key2")
+		lru.Add([]byte("key2"), "val2", 0)
+		lru.Add([]byte("key1"), "val1", 0)
+		assert.Equal(t, []WatchEvent[string]{{Op: EventAdd, Value: "val1"}}, drainWatch(ch))
+		cancel()
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("cancel did not find the watch")
+		}
+		assert.Empty(t, lru.watches)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		// cancel和Close关闭channel
+		lru, _ := NewPriorityLRU[int, int](2, 1, nil, nil)
+		ch1, cancel1 := lru.Watch(1)
+		ch2, _ := lru.Watch(1)
+		cancel1()
+		cancel1()
+		_, ok := <-ch1
+		assert.False(t, ok)
+		assert.Len(t, lru.watches, 1)
+		assert.NoError(t, lru.Close())
+		_, ok = <-ch2
+		assert.False(t, ok)
+		ch3, _ := lru.Watch(1)
+		_, ok = <-ch3
+		assert.False(t, ok)
+	})
+}
+
+func drainWatch[V any](ch <-chan WatchEvent[V]) []WatchEvent[V] {
+	var events []WatchEvent[V]
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
@@ -0,0 +1,622 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jlist "github.com/junjiefly/jlru/list"
+)
+
+// Store is the slow storage behind the cache, see Options.Store.
+type Store[K any, V any] interface {
+	// Load returns the value of key and whether the store has it.
+	Load(ctx context.Context, key K) (V, bool, error)
+	// Save stores the value of key.
+	Save(ctx context.Context, key K, value V) error
+	// Delete drops key, it is not an error if the store does not have it.
+	Delete(ctx context.Context, key K) error
+}
+
+// ErrStoreRequired is returned by the calls which need Options.Store.
+var ErrStoreRequired = errors.New("StoreRequired")
+
+const defaultRetryBackoff = 100 * time.Millisecond
+const defaultMaxRetryBackoff = 30 * time.Second
+
+// dirtyEntry is the write-back state of an entry which is not saved yet.
+type dirtyEntry struct {
+	version  uint64 // write which made the entry dirty
+	pinned   bool   // the last save failed, the entry waits in the highest band
+	priority byte   // priority before pinning
+	attempts uint32
+	retryAt  time.Time
+}
+
+// writeBack keeps the dirty entries by arena idx.
+type writeBack struct {
+	dirty      map[uint32]dirtyEntry
+	writes     uint64
+	pinned     int
+	nextRetry  time.Time   // earliest retryAt of the pinned entries
+	timer      *time.Timer // runs the retries at nextRetry, see scheduleRetry
+	backoff    time.Duration
+	maxBackoff time.Duration
+	saving     int        // victims out of the cache whose save is in flight, they keep their room
+	settled    *sync.Cond // signaled when saving drops, on the cache lock
+	skipped    []skippedVictim
+	busy       *sync.Mutex // key lock of the last skipped victim, see errKeyBusy
+}
+
+// skippedVictim is a dirty victim whose key was busy in the store, it is moved
+// to the highest band for one eviction, see skipVictim.
+type skippedVictim struct {
+	idx      uint32
+	priority byte
+}
+
+// saveJob is a save queued under the lock, it runs after the lock is released
+// with the key lock held, see runSaves.
+type saveJob[K any, V any] struct {
+	key      K
+	value    V
+	priority byte
+	dirty    dirtyEntry // state when the save was queued
+	detached bool       // a victim taken out of the cache, else a pinned entry
+	mu       *sync.Mutex
+	err      error
+}
+
+// errSaveInFlight tells the writers that the cache is full of victims whose
+// save is in flight, they retry after waitSaves.
+var errSaveInFlight = errors.New("SaveInFlight")
+
+// errKeyBusy tells the writers that the dirty victims were skipped as their
+// keys are busy in the store, they retry after waitSaves.
+var errKeyBusy = errors.New("KeyBusy")
+
+func newWriteBack(backoff time.Duration, maxBackoff time.Duration) *writeBack {
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if maxBackoff < backoff {
+		maxBackoff = defaultMaxRetryBackoff
+		if maxBackoff < backoff {
+			maxBackoff = backoff
+		}
+	}
+	return &writeBack{
+		dirty:      make(map[uint32]dirtyEntry),
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+	}
+}
+
+func (wb *writeBack) reset() {
+	for idx := range wb.dirty {
+		delete(wb.dirty, idx)
+	}
+	wb.pinned = 0
+}
+
+// markDirty records a write of e, a pinned entry got a new priority from the
+// write and is no longer pinned.
+func (lru *LRU[K, V]) markDirty(e *jlist.Entry[K, V]) {
+	wb := lru.writeBack
+	if wb.dirty[e.Idx()].pinned {
+		wb.pinned--
+	}
+	wb.writes++
+	wb.dirty[e.Idx()] = dirtyEntry{version: wb.writes}
+}
+
+// dropDirty forgets the write-back state of an entry leaving the cache.
+func (lru *LRU[K, V]) dropDirty(e *jlist.Entry[K, V]) {
+	wb := lru.writeBack
+	d, ok := wb.dirty[e.Idx()]
+	if !ok {
+		return
+	}
+	if d.pinned {
+		wb.pinned--
+	}
+	delete(wb.dirty, e.Idx())
+}
+
+// markClean drops the dirty state of a saved entry, a pinned entry gets its
+// priority back and may be evicted again.
+func (lru *LRU[K, V]) markClean(e *jlist.Entry[K, V]) {
+	d := lru.writeBack.dirty[e.Idx()]
+	lru.dropDirty(e)
+	if d.pinned {
+		lru.setPriority(e, d.priority)
+		if lru.touch(e) != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+		}
+	}
+}
+
+// pin keeps an entry which failed to save in the highest band, which is never
+// evicted, until a retry succeeds.
+func (lru *LRU[K, V]) pin(e *jlist.Entry[K, V], now time.Time) {
+	wb := lru.writeBack
+	d := wb.dirty[e.Idx()]
+	if !d.pinned {
+		d.pinned = true
+		d.priority = e.Priority
+		wb.pinned++
+		lru.setPriority(e, lru.maxPriority)
+		if lru.touch(e) != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+		}
+	}
+	backoff := wb.backoff << d.attempts
+	if backoff > wb.maxBackoff || backoff <= 0 {
+		backoff = wb.maxBackoff
+	}
+	d.attempts++
+	d.retryAt = now.Add(backoff)
+	if wb.pinned == 1 || d.retryAt.Before(wb.nextRetry) {
+		wb.nextRetry = d.retryAt
+	}
+	wb.dirty[e.Idx()] = d
+	lru.scheduleRetry()
+}
+
+// scheduleRetry sets the timer to nextRetry, so the pinned entries are saved
+// again without waiting for an eviction.
+func (lru *LRU[K, V]) scheduleRetry() {
+	wb := lru.writeBack
+	if wb.pinned == 0 || lru.closed {
+		return
+	}
+	wait := time.Until(wb.nextRetry)
+	if wb.timer == nil {
+		wb.timer = time.AfterFunc(wait, lru.retryTimer)
+		return
+	}
+	wb.timer.Reset(wait)
+}
+
+// retryTimer queues the saves of the pinned entries which are due, unlock runs
+// them.
+func (lru *LRU[K, V]) retryTimer() {
+	lru.Lock()
+	defer lru.unlock()
+	if lru.closed {
+		return
+	}
+	lru.retryPinned()
+	// not due yet if the timer fired early
+	lru.scheduleRetry()
+}
+
+// victim returns the entry to evict. In write-back mode a dirty victim is
+// taken out of the cache instead, see detachVictim, and nil is returned.
+func (lru *LRU[K, V]) victim() *jlist.Entry[K, V] {
+	if lru.writeBack != nil && lru.detachVictim() {
+		return nil
+	}
+	return lru.oldest()
+}
+
+// detachVictim takes the oldest entry out of the cache if it is dirty, it is
+// saved by unlock and keeps its room until then, see errSaveInFlight. A dirty
+// victim whose key is busy in the store is skipped, and the next oldest is
+// tried. It reports whether a victim was taken out.
+//
+// The skipped victims stay out of the way until restoreSkipped, which the
+// caller runs after the eviction.
+func (lru *LRU[K, V]) detachVictim() bool {
+	lru.retryPinned()
+	for {
+		e := lru.oldest()
+		if e == nil {
+			return false
+		}
+		d, ok := lru.writeBack.dirty[e.Idx()]
+		if !ok {
+			return false
+		}
+		mu := lru.keyLocks.tryLock(e.Key)
+		if mu == nil {
+			lru.skipVictim(e)
+			continue
+		}
+		job := saveJob[K, V]{key: e.Key, value: e.Value, priority: e.Priority, dirty: d, detached: true, mu: mu}
+		if lru.removeElement(e, true) != nil {
+			mu.Unlock()
+			return false
+		}
+		if _, ok = lru.writeBack.dirty[e.Idx()]; ok {
+			// kept by OnEvicted
+			mu.Unlock()
+			return false
+		}
+		atomic.AddUint64(&lru.metrics.Evictions, 1)
+		lru.writeBack.saving++
+		lru.saves = append(lru.saves, job)
+		return true
+	}
+}
+
+// skipVictim moves a dirty victim whose key is busy to the highest band, which
+// is never evicted. It is not pinned, as its save did not fail.
+func (lru *LRU[K, V]) skipVictim(e *jlist.Entry[K, V]) {
+	wb := lru.writeBack
+	wb.skipped = append(wb.skipped, skippedVictim{idx: e.Idx(), priority: e.Priority})
+	wb.busy = lru.keyLocks.stripe(e.Key)
+	lru.moveToBand(e, lru.maxPriority, false)
+}
+
+// restoreSkipped moves the skipped victims back to the back of their band, so
+// they go first in the next eviction. It reports whether there were any.
+func (lru *LRU[K, V]) restoreSkipped() bool {
+	wb := lru.writeBack
+	if len(wb.skipped) == 0 {
+		return false
+	}
+	for _, s := range wb.skipped {
+		e, err := lru.ll.Entry(s.idx)
+		if err != nil || e.Flag != 0 {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			continue
+		}
+		lru.moveToBand(e, s.priority, true)
+	}
+	wb.skipped = wb.skipped[:0]
+	return true
+}
+
+// moveToBand moves e to the front or the back of the band of priority without
+// counting a hit.
+func (lru *LRU[K, V]) moveToBand(e *jlist.Entry[K, V], priority byte, toBack bool) {
+	lru.setPriority(e, priority)
+	if lru.lfu != nil {
+		// relinked by lfuMove
+		return
+	}
+	var markNode *jlist.Entry[K, V]
+	var err error
+	if toBack {
+		if markNode, err = lru.backMarkNode(priority, lru.segment(e)); err == nil {
+			err = lru.ll.MoveBefore(e, markNode)
+		}
+	} else {
+		if markNode, err = lru.frontMarkNode(priority, lru.segment(e)); err == nil {
+			err = lru.ll.MoveAfter(e, markNode)
+		}
+	}
+	if err != nil {
+		atomic.AddUint64(&lru.metrics.Errors, 1)
+	}
+}
+
+// retryPinned queues the saves of the pinned entries whose backoff has passed,
+// it is called by the timer of the retries and before an eviction, so the
+// saved ones may be evicted.
+func (lru *LRU[K, V]) retryPinned() {
+	wb := lru.writeBack
+	if wb.pinned == 0 {
+		return
+	}
+	now := time.Now()
+	if now.Before(wb.nextRetry) {
+		return
+	}
+	wb.nextRetry = now.Add(wb.maxBackoff)
+	for idx, d := range wb.dirty {
+		if !d.pinned {
+			continue
+		}
+		if now.Before(d.retryAt) {
+			if d.retryAt.Before(wb.nextRetry) {
+				wb.nextRetry = d.retryAt
+			}
+			continue
+		}
+		e, err := lru.ll.Entry(idx)
+		if err != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			continue
+		}
+		mu := lru.keyLocks.tryLock(e.Key)
+		if mu == nil {
+			// busy in the store, retried after a backoff
+			if next := now.Add(wb.backoff); next.Before(wb.nextRetry) {
+				wb.nextRetry = next
+			}
+			continue
+		}
+		lru.saves = append(lru.saves, saveJob[K, V]{key: e.Key, value: e.Value, dirty: d, mu: mu})
+	}
+	lru.scheduleRetry()
+}
+
+// runSaves runs the saves queued under the lock, which is released, then
+// settles them under the lock and releases their key locks.
+func (lru *LRU[K, V]) runSaves(saves []saveJob[K, V]) {
+	for i := range saves {
+		saves[i].err = lru.store.Save(context.Background(), saves[i].key, saves[i].value)
+		if saves[i].err != nil {
+			atomic.AddUint64(&lru.metrics.SaveErrors, 1)
+		}
+	}
+	now := time.Now()
+	lru.Lock()
+	for i := range saves {
+		lru.settleSave(&saves[i], now)
+	}
+	lru.Unlock()
+	for i := range saves {
+		saves[i].mu.Unlock()
+	}
+}
+
+// settleSave applies the result of a save. A saved pinned entry gets its
+// priority back, a victim which failed to save is added back pinned into the
+// room it kept, unless its key was written again meanwhile.
+func (lru *LRU[K, V]) settleSave(job *saveJob[K, V], now time.Time) {
+	wb := lru.writeBack
+	if job.detached {
+		wb.saving--
+		wb.settled.Broadcast()
+	}
+	if lru.closed || (job.detached && job.err == nil) {
+		return
+	}
+	hashId, bukPos := lru.hashToPos(job.key)
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, job.key)
+	if err != nil {
+		atomic.AddUint64(&lru.metrics.Errors, 1)
+		return
+	}
+	if job.detached {
+		if ok {
+			return
+		}
+		e, err = lru.insertEntry(job.key, job.value, hashId, bukPos, job.priority, false)
+		if err != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			return
+		}
+		wb.dirty[e.Idx()] = job.dirty
+		lru.pin(e, now)
+		lru.changed(EventAdd, hashId, job.key, job.value, job.priority)
+		return
+	}
+	if !ok {
+		return
+	}
+	if d, dirty := wb.dirty[e.Idx()]; !dirty || d.version != job.dirty.version {
+		return
+	}
+	if job.err != nil {
+		lru.pin(e, now)
+		return
+	}
+	lru.markClean(e)
+}
+
+// waitSaves reports whether err is errSaveInFlight or errKeyBusy. Then it
+// waits until a save in flight is settled or there is room in the cache, or
+// until the key lock of a skipped victim is released. The caller must not hold
+// a key lock.
+func (lru *LRU[K, V]) waitSaves(err error) bool {
+	switch err {
+	case errSaveInFlight:
+		lru.Lock()
+		for lru.writeBack.saving > 0 && lru.full() {
+			lru.writeBack.settled.Wait()
+		}
+		lru.Unlock()
+		return true
+	case errKeyBusy:
+		lru.Lock()
+		mu := lru.writeBack.busy
+		lru.Unlock()
+		mu.Lock()
+		mu.Unlock()
+		return true
+	}
+	return false
+}
+
+// Flush saves all the dirty entries, including the pinned ones, without
+// holding the lock during the saves. The key lock is held across each save, so
+// a later save or Remove of the key waits for it, and an entry written again
+// meanwhile stays dirty. It returns the first error, the failed entries stay
+// dirty. In write-through mode there is nothing to flush.
+func (lru *LRU[K, V]) Flush(ctx context.Context) error {
+	if lru.store == nil {
+		return ErrStoreRequired
+	}
+	if lru.writeBack == nil {
+		return nil
+	}
+	lru.Lock()
+	for lru.writeBack.saving > 0 {
+		lru.writeBack.settled.Wait()
+	}
+	if lru.closed {
+		lru.Unlock()
+		return ErrClosed
+	}
+	keys := make([]K, 0, len(lru.writeBack.dirty))
+	for idx := range lru.writeBack.dirty {
+		e, err := lru.ll.Entry(idx)
+		if err != nil {
+			atomic.AddUint64(&lru.metrics.Errors, 1)
+			continue
+		}
+		keys = append(keys, e.Key)
+	}
+	lru.Unlock()
+	var firstErr error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mu := lru.keyLocks.lock(key)
+		err := lru.flushKey(ctx, key)
+		mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// lockFlushed takes the lock with no dirty entry left, for the calls which
+// drop the entries. In write-back mode the dirty entries are flushed first,
+// on an error of Flush the lock is not taken and the error is returned.
+func (lru *LRU[K, V]) lockFlushed() error {
+	for {
+		lru.Lock()
+		wb := lru.writeBack
+		if wb == nil || lru.closed || len(wb.dirty)+wb.saving == 0 {
+			return nil
+		}
+		lru.Unlock()
+		if err := lru.Flush(context.Background()); err != nil {
+			return err
+		}
+	}
+}
+
+// flushKey saves key if it is still dirty, the key lock must be held.
+func (lru *LRU[K, V]) flushKey(ctx context.Context, key K) error {
+	e, ok, err := lru.findKey(key)
+	if err != nil || !ok {
+		lru.Unlock()
+		return err
+	}
+	d, dirty := lru.writeBack.dirty[e.Idx()]
+	value := e.Value
+	lru.Unlock()
+	if !dirty {
+		return nil
+	}
+	if err = lru.store.Save(ctx, key, value); err != nil {
+		atomic.AddUint64(&lru.metrics.SaveErrors, 1)
+		return fmt.Errorf("flush err: %s", err.Error())
+	}
+	e, ok, err = lru.findKey(key)
+	defer lru.Unlock()
+	if err != nil || !ok {
+		return err
+	}
+	if cur, dirty := lru.writeBack.dirty[e.Idx()]; dirty && cur.version == d.version {
+		lru.markClean(e)
+	}
+	return nil
+}
+
+// findKey takes the lock and looks key up, the lock is held on return.
+func (lru *LRU[K, V]) findKey(key K) (*jlist.Entry[K, V], bool, error) {
+	hashId, bukPos := lru.lockKey(key)
+	if lru.closed {
+		return nil, false, ErrClosed
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
+	if err != nil {
+		atomic.AddUint64(&lru.metrics.Errors, 1)
+		return nil, false, fmt.Errorf("flush err: %s", err.Error())
+	}
+	return e, ok, nil
+}
+
+// Dirty returns the number of entries not saved yet, the evicted ones being
+// saved included, and how many of them are pinned after a failed save.
+func (lru *LRU[K, V]) Dirty() (dirty int, pinned int) {
+	if lru.writeBack == nil {
+		return 0, 0
+	}
+	lru.RLock()
+	defer lru.RUnlock()
+	return len(lru.writeBack.dirty) + lru.writeBack.saving, lru.writeBack.pinned
+}
+
+// GetOrLoad returns the value of key, it is loaded from the Store on a miss
+// and cached clean with priority. A key added meanwhile is not overwritten.
+func (lru *LRU[K, V]) GetOrLoad(ctx context.Context, key K, priority byte) (value V, ok bool, err error) {
+	if lru.store == nil {
+		return value, false, ErrStoreRequired
+	}
+	value, ok, err = lru.Get(key)
+	if err != nil || ok {
+		return value, ok, err
+	}
+	for {
+		var mu *sync.Mutex
+		if lru.keyLocks != nil {
+			// a write or a save of key must not be overwritten by an older loaded value
+			mu = lru.keyLocks.lock(key)
+		}
+		value, ok, err = lru.store.Load(ctx, key)
+		if err != nil || !ok {
+			if mu != nil {
+				mu.Unlock()
+			}
+			if err != nil {
+				return value, false, fmt.Errorf("load err: %s", err.Error())
+			}
+			return value, false, nil
+		}
+		loaded, err := lru.addLoaded(key, value, priority, mu)
+		if !lru.waitSaves(err) {
+			return loaded, err == nil, err
+		}
+	}
+}
+
+// addLoaded caches a loaded value unless the key is cached already, and
+// returns the cached value. It takes over keyLock like add.
+func (lru *LRU[K, V]) addLoaded(key K, value V, priority byte, keyLock *sync.Mutex) (V, error) {
+	if priority > lru.maxPriority {
+		priority = lru.maxPriority
+	}
+	var hook hookCall[K, V]
+	if lru.hooks != nil && !lru.hooks.InLock {
+		defer lru.runHook(&hook)
+	}
+	hashId, bukPos := lru.lockKey(key)
+	lru.keyLock = keyLock
+	defer lru.unlock()
+	if lru.hooks != nil && lru.hooks.InLock {
+		defer lru.runHook(&hook)
+	}
+	if lru.closed {
+		return value, ErrClosed
+	}
+	if lru.readBufs != nil {
+		lru.drainReadBuffers()
+	}
+	if lru.sketch != nil {
+		lru.sketch.age()
+		lru.sketch.increment(hashId)
+	}
+	e, ok, err := lru.getEntryInBuk(bukPos, hashId, key)
+	if err != nil {
+		return value, fmt.Errorf("load err: %s", err.Error())
+	}
+	if ok {
+		return e.Value, nil
+	}
+	_, err = lru.insertEntry(key, value, hashId, bukPos, priority, false)
+	if err == ErrRejected {
+		return value, nil
+	}
+	if err == errSaveInFlight || err == errKeyBusy {
+		return value, err
+	}
+	if err != nil {
+		return value, fmt.Errorf("load err: %s", err.Error())
+	}
+	lru.inserted(&hook, key, value)
+	lru.changed(EventAdd, hashId, key, value, priority)
+	return value, nil
+}
@@ -0,0 +1,450 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore 内存中的Store，fail中的key保存和删除失败，beforeSave在写入前调用
+type memStore struct {
+	sync.Mutex
+	data       map[string]string
+	fail       map[string]bool
+	loads      int
+	saves      int
+	onSave     func(key string)
+	beforeSave func(key string)
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string), fail: make(map[string]bool)}
+}
+
+func (s *memStore) Load(ctx context.Context, key string) (string, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.loads++
+	value, ok := s.data[key]
+	return value, ok, nil
+}
+
+func (s *memStore) Save(ctx context.Context, key string, value string) error {
+	s.Lock()
+	beforeSave := s.beforeSave
+	s.Unlock()
+	if beforeSave != nil {
+		beforeSave(key)
+	}
+	s.Lock()
+	s.saves++
+	if s.fail[key] {
+		s.Unlock()
+		return errors.New("store unavailable")
+	}
+	s.data[key] = value
+	onSave := s.onSave
+	s.Unlock()
+	if onSave != nil {
+		onSave(key)
+	}
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.fail[key] {
+		return errors.New("store unavailable")
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memStore) get(key string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func newWriteBackLRU(capacity int, store *memStore) *LRU[string, string] {
+	lru, _ := NewPriorityLRUWithOptions[string, string](capacity, 2, Options[string, string]{
+		Store:        store,
+		WriteBack:    true,
+		RetryBackoff: time.Millisecond,
+	})
+	return lru
+}
+
+func TestWriteBack(t *testing.T) {
+	t.Run("evict_saves", func(t *testing.T) {
+		// 写入只标记为脏，驱逐时先保存
+		store := newMemStore()
+		lru := newWriteBackLRU(2, store)
+		lru.Add("key1", "val1", 0)
+		lru.Add("key1", "val2", 0)
+		lru.Add("key2", "val3", 0)
+		assert.Equal(t, 0, store.saves)
+		dirty, pinned := lru.Dirty()
+		assert.Equal(t, 2, dirty)
+		assert.Equal(t, 0, pinned)
+		lru.Add("key3", "val4", 0) // 驱逐key1
+		value, ok := store.get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, "val2", value)
+		assert.Equal(t, 1, store.saves)
+		dirty, _ = lru.Dirty()
+		assert.Equal(t, 2, dirty)
+	})
+
+	t.Run("pin_and_retry", func(t *testing.T) {
+		// 保存失败的节点被固定在最高优先级，退避后重试成功再恢复优先级
+		store := newMemStore()
+		store.fail["key1"] = true
+		lru := newWriteBackLRU(2, store)
+		lru.Add("key1", "val1", 0)
+		lru.Add("key2", "val2", 0)
+		lru.Add("key3", "val3", 0) // key1保存失败被固定，驱逐key2
+		_, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		_, ok, _ = lru.Get("key2")
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), lru.Metrics().SaveErrors)
+		dirty, pinned := lru.Dirty()
+		assert.Equal(t, 2, dirty)
+		assert.Equal(t, 1, pinned)
+		assert.Equal(t, uint8(2), findEntry(lru, "key1").Priority)
+
+		store.Lock()
+		store.fail["key1"] = false
+		store.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		lru.Add("key4", "val4", 0) // 重试key1成功，key1回到优先级0
+		value, ok := store.get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		dirty, pinned = lru.Dirty()
+		assert.Equal(t, 0, pinned)
+		assert.Equal(t, 1, dirty)
+		lru.Add("key5", "val5", 0)
+		lru.Add("key6", "val6", 0)
+		_, ok, _ = lru.Get("key1")
+		assert.False(t, ok)
+	})
+
+	t.Run("retry_timer", func(t *testing.T) {
+		// 没有驱逐和Flush时，固定的节点也会在退避后重试保存
+		store := newMemStore()
+		store.fail["key1"] = true
+		lru := newWriteBackLRU(2, store)
+		lru.Add("key1", "val1", 0)
+		lru.Add("key2", "val2", 0)
+		lru.Add("key3", "val3", 0) // key1保存失败被固定
+		_, pinned := lru.Dirty()
+		assert.Equal(t, 1, pinned)
+		store.Lock()
+		store.fail["key1"] = false
+		store.Unlock()
+		assert.Eventually(t, func() bool {
+			_, pinned := lru.Dirty()
+			return pinned == 0
+		}, time.Second, time.Millisecond)
+		value, ok := store.get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 1, dirty)
+		assert.Equal(t, uint8(0), findEntry(lru, "key1").Priority)
+		assert.NoError(t, lru.Close())
+	})
+
+	t.Run("all_pinned", func(t *testing.T) {
+		// 所有节点都无法保存时写入失败
+		store := newMemStore()
+		store.fail["key1"] = true
+		store.fail["key2"] = true
+		lru := newWriteBackLRU(2, store)
+		lru.Add("key1", "val1", 0)
+		lru.Add("key2", "val2", 0)
+		assert.Error(t, lru.Add("key3", "val3", 0))
+		assert.Equal(t, uint32(2), lru.Len())
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		// Flush保存所有脏节点，保存期间再次写入的节点仍为脏
+		store := newMemStore()
+		lru := newWriteBackLRU(10, store)
+		for i := 0; i < 5; i++ {
+			lru.Add(fmt.Sprintf("key%d", i), "val", 0)
+		}
+		store.onSave = func(key string) {
+			if key == "key0" {
+				lru.Add("key0", "new", 0)
+			}
+		}
+		assert.NoError(t, lru.Flush(context.Background()))
+		assert.Equal(t, 5, store.saves)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 1, dirty)
+		store.onSave = nil
+		assert.NoError(t, lru.Flush(context.Background()))
+		value, _ := store.get("key0")
+		assert.Equal(t, "new", value)
+		dirty, _ = lru.Dirty()
+		assert.Equal(t, 0, dirty)
+
+		store.fail["key1"] = true
+		lru.Add("key1", "val", 0)
+		assert.Error(t, lru.Flush(context.Background()))
+		dirty, _ = lru.Dirty()
+		assert.Equal(t, 1, dirty)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, lru.Flush(ctx))
+	})
+
+	t.Run("flush_holds_key", func(t *testing.T) {
+		// Flush保存期间同一个key的Remove等待保存完成，Store中不会留下旧值
+		store := newMemStore()
+		lru := newWriteBackLRU(10, store)
+		lru.Add("key1", "val1", 0)
+		saving := make(chan struct{})
+		release := make(chan struct{})
+		store.beforeSave = func(key string) {
+			close(saving)
+			<-release
+		}
+		flushed := make(chan error)
+		go func() { flushed <- lru.Flush(context.Background()) }()
+		<-saving
+		removed := make(chan struct{})
+		go func() {
+			lru.Remove("key1")
+			close(removed)
+		}()
+		select {
+		case <-removed:
+			t.Fatal("remove did not wait for the save")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		assert.NoError(t, <-flushed)
+		<-removed
+		_, ok := store.get("key1")
+		assert.False(t, ok)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 0, dirty)
+	})
+
+	t.Run("evict_outside_lock", func(t *testing.T) {
+		// 驱逐时在锁外保存，其他key不受影响，同一个key的GetOrLoad和Remove等待保存完成
+		store := newMemStore()
+		store.data["key1"] = "old"
+		lru := newWriteBackLRU(2, store)
+		lru.Add("key1", "new", 0)
+		lru.Add("key2", "val2", 0)
+		saving := make(chan struct{})
+		release := make(chan struct{})
+		store.beforeSave = func(key string) {
+			if key == "key1" {
+				close(saving)
+				<-release
+			}
+		}
+		added := make(chan error)
+		go func() { added <- lru.Add("key3", "val3", 0) }()
+		<-saving
+		got := make(chan bool)
+		go func() {
+			_, ok, _ := lru.Get("key2")
+			got <- ok
+		}()
+		select {
+		case ok := <-got:
+			assert.True(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("get waited for the save")
+		}
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 2, dirty)
+		var loaded string
+		removed := make(chan struct{})
+		go func() {
+			loaded, _, _ = lru.GetOrLoad(context.Background(), "key1", 0)
+			lru.Remove("key1")
+			close(removed)
+		}()
+		select {
+		case <-removed:
+			t.Fatal("remove did not wait for the save")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		assert.NoError(t, <-added)
+		<-removed
+		assert.Equal(t, "new", loaded)
+		_, ok := store.get("key1")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key3")
+		assert.True(t, ok)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		// 并发写入、驱逐、Flush和Remove后，缓存中的值与Store一致
+		store := newMemStore()
+		lru := newWriteBackLRU(4, store)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				r := rand.New(rand.NewSource(int64(g)))
+				for i := 0; i < 500; i++ {
+					key := fmt.Sprintf("key%d", r.Intn(8))
+					switch r.Intn(6) {
+					case 0:
+						lru.Remove(key)
+					case 1:
+						lru.GetOrLoad(context.Background(), key, 0)
+					case 2:
+						lru.Flush(context.Background())
+					default:
+						lru.Add(key, fmt.Sprintf("%d-%d", g, i), 0)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.NoError(t, lru.Flush(context.Background()))
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 0, dirty)
+		assert.Equal(t, uint64(0), lru.Metrics().Errors)
+		for i := 0; i < 8; i++ {
+			key := fmt.Sprintf("key%d", i)
+			value, ok, _ := lru.Get(key)
+			if !ok {
+				continue
+			}
+			stored, _ := store.get(key)
+			assert.Equal(t, stored, value)
+		}
+	})
+
+	t.Run("busy_not_pinned", func(t *testing.T) {
+		// key锁被占用的脏节点只是跳过，不会被固定
+		for _, policy := range []Policy{PolicyLRU, PolicyClock, PolicyARC, PolicySLRU, PolicyLFU, PolicyGDSF, PolicyLRU2, PolicySIEVE} {
+			store := newMemStore()
+			lru, _ := NewPriorityLRUWithOptions[string, string](16, 2, Options[string, string]{
+				Store:     store,
+				WriteBack: true,
+				Policy:    policy,
+			})
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			maxPinned := 0
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					r := rand.New(rand.NewSource(int64(g)))
+					for i := 0; i < 500; i++ {
+						key := fmt.Sprintf("key%d", r.Intn(32))
+						if r.Intn(2) == 0 {
+							assert.NoError(t, lru.Add(key, fmt.Sprintf("%d-%d", g, i), 0))
+						} else {
+							_, _, err := lru.GetOrLoad(context.Background(), key, 0)
+							assert.NoError(t, err)
+						}
+						_, pinned := lru.Dirty()
+						mu.Lock()
+						if pinned > maxPinned {
+							maxPinned = pinned
+						}
+						mu.Unlock()
+					}
+				}(g)
+			}
+			wg.Wait()
+			assert.Equal(t, 0, maxPinned, policy)
+			assert.Equal(t, uint64(0), lru.Metrics().SaveErrors)
+			assert.Equal(t, uint64(0), lru.Metrics().Errors, policy)
+			assert.NoError(t, lru.Flush(context.Background()))
+			for i := 0; i < 32; i++ {
+				key := fmt.Sprintf("key%d", i)
+				if value, ok, _ := lru.Get(key); ok {
+					stored, _ := store.get(key)
+					assert.Equal(t, stored, value)
+				}
+			}
+		}
+	})
+
+	t.Run("purge_and_close", func(t *testing.T) {
+		// Purge和Close先保存脏节点，保存失败时返回错误并保持缓存不变
+		store := newMemStore()
+		lru := newWriteBackLRU(10, store)
+		lru.Add("key1", "val1", 0)
+		assert.NoError(t, lru.Purge())
+		value, ok := store.get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		assert.Equal(t, uint32(0), lru.Len())
+
+		store.fail["key2"] = true
+		lru.Add("key2", "val2", 0)
+		assert.Error(t, lru.Purge())
+		assert.Error(t, lru.Close())
+		lru.Clear()
+		value, ok, _ = lru.Get("key2")
+		assert.True(t, ok)
+		assert.Equal(t, "val2", value)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 1, dirty)
+
+		store.Lock()
+		store.fail["key2"] = false
+		store.Unlock()
+		assert.NoError(t, lru.Close())
+		value, ok = store.get("key2")
+		assert.True(t, ok)
+		assert.Equal(t, "val2", value)
+		assert.Equal(t, ErrClosed, lru.Close())
+	})
+
+	t.Run("remove_and_load", func(t *testing.T) {
+		// Remove从Store中删除，GetOrLoad读入的节点不是脏节点
+		store := newMemStore()
+		store.data["key1"] = "val1"
+		store.data["key2"] = "val2"
+		lru := newWriteBackLRU(2, store)
+		value, ok, err := lru.GetOrLoad(context.Background(), "key1", 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		lru.GetOrLoad(context.Background(), "key1", 0)
+		assert.Equal(t, 1, store.loads)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 0, dirty)
+		_, ok, _ = lru.GetOrLoad(context.Background(), "key3", 0)
+		assert.False(t, ok)
+
+		lru.Remove("key2")
+		_, ok = store.get("key2")
+		assert.False(t, ok)
+	})
+
+	t.Run("store_required", func(t *testing.T) {
+		_, err := NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{WriteBack: true})
+		assert.Equal(t, ErrStoreRequired, err)
+		lru, _ := NewPriorityLRU[string, string](2, 1, nil, nil)
+		assert.Equal(t, ErrStoreRequired, lru.Flush(context.Background()))
+		_, _, err = lru.GetOrLoad(context.Background(), "key1", 0)
+		assert.Equal(t, ErrStoreRequired, err)
+	})
+}
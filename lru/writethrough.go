@@ -0,0 +1,86 @@
+package lru
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+const keyLockStripes = 64
+
+// keyLocks serializes the writers of a key in write-through mode, so the store
+// and the cache see the writes of a key in the same order. In write-back mode
+// it is held across the saves and deletes of a key, so they reach the store in
+// order. The keys share a fixed number of mutexes, the saves of different
+// stripes run in parallel.
+type keyLocks[K any] struct {
+	hash  func(K) uint64
+	locks [keyLockStripes]sync.Mutex
+}
+
+// newKeyLocks picks the stripe hash. A seeded cache gets a seed of its own for
+// the stripes, as the one of the buckets changes on a reseed and a key must
+// stay on the same stripe.
+func newKeyLocks[K any](hashFunc HashKeyCallback64[K], seeded *seededHash[K]) *keyLocks[K] {
+	if seeded != nil {
+		seed := maphash.MakeSeed()
+		hash := seeded.hash
+		hashFunc = func(k K) uint64 {
+			return hash(seed, k)
+		}
+	}
+	return &keyLocks[K]{hash: hashFunc}
+}
+
+func (kl *keyLocks[K]) stripe(key K) *sync.Mutex {
+	return &kl.locks[kl.hash(key)%keyLockStripes]
+}
+
+func (kl *keyLocks[K]) lock(key K) *sync.Mutex {
+	mu := kl.stripe(key)
+	mu.Lock()
+	return mu
+}
+
+// tryLock is lock for the callers which hold the cache lock, it returns nil
+// if the stripe is busy.
+func (kl *keyLocks[K]) tryLock(key K) *sync.Mutex {
+	mu := kl.stripe(key)
+	if !mu.TryLock() {
+		return nil
+	}
+	return mu
+}
+
+// saveThrough saves a write to the store before the cache sees it, the key
+// lock must be held.
+func (lru *LRU[K, V]) saveThrough(key K, value V) error {
+	lru.RLock()
+	closed := lru.closed
+	lru.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if err := lru.store.Save(context.Background(), key, value); err != nil {
+		atomic.AddUint64(&lru.metrics.SaveErrors, 1)
+		return fmt.Errorf("save err: %s", err.Error())
+	}
+	return nil
+}
+
+// deleteThrough deletes a key from the store before the cache drops it, the
+// key lock must be held.
+func (lru *LRU[K, V]) deleteThrough(key K) error {
+	lru.RLock()
+	closed := lru.closed
+	lru.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if err := lru.store.Delete(context.Background(), key); err != nil {
+		return fmt.Errorf("delete err: %s", err.Error())
+	}
+	return nil
+}
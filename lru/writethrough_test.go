@@ -0,0 +1,206 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newWriteThroughLRU(capacity int, store *memStore) *LRU[string, string] {
+	lru, _ := NewPriorityLRUWithOptions[string, string](capacity, 1, Options[string, string]{
+		Store:        store,
+		WriteThrough: true,
+	})
+	return lru
+}
+
+func TestWriteThrough(t *testing.T) {
+	t.Run("save_first", func(t *testing.T) {
+		// 先写入Store，保存时缓存中还是旧值
+		store := newMemStore()
+		lru := newWriteThroughLRU(2, store)
+		var seen []string
+		store.onSave = func(key string) {
+			value, _, _ := lru.Get(key)
+			seen = append(seen, value)
+		}
+		assert.NoError(t, lru.Add("key1", "val1", 0))
+		assert.NoError(t, lru.AddToBack("key1", "val2", 0))
+		assert.Equal(t, []string{"", "val1"}, seen)
+		value, _ := store.get("key1")
+		assert.Equal(t, "val2", value)
+		value, _, _ = lru.Get("key1")
+		assert.Equal(t, "val2", value)
+		dirty, _ := lru.Dirty()
+		assert.Equal(t, 0, dirty)
+		assert.NoError(t, lru.Flush(context.Background()))
+	})
+
+	t.Run("store_error", func(t *testing.T) {
+		// Store失败时缓存不变
+		store := newMemStore()
+		lru := newWriteThroughLRU(2, store)
+		lru.Add("key1", "val1", 0)
+		store.fail["key1"] = true
+		store.fail["key2"] = true
+		assert.Error(t, lru.Add("key1", "val2", 0))
+		assert.Error(t, lru.AddToBack("key2", "val3", 0))
+		assert.Equal(t, uint64(2), lru.Metrics().SaveErrors)
+		value, ok, _ := lru.Get("key1")
+		assert.True(t, ok)
+		assert.Equal(t, "val1", value)
+		_, ok, _ = lru.Get("key2")
+		assert.False(t, ok)
+
+		_, ok, err := lru.Remove("key1")
+		assert.Error(t, err)
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key1")
+		assert.True(t, ok)
+		store.fail["key1"] = false
+		_, ok, err = lru.Remove("key1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		_, ok = store.get("key1")
+		assert.False(t, ok)
+	})
+
+	t.Run("callbacks_write", func(t *testing.T) {
+		// 回调和锁外的钩子在释放key锁之后执行，可以写入同一个key
+		store := newMemStore()
+		var lru *LRU[string, string]
+		lru, _ = NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{
+			Store:        store,
+			WriteThrough: true,
+			OnEvicted: func(key string, value string) bool {
+				if key == "key1" {
+					assert.NoError(t, lru.Add("key3", "val4", 0))
+				}
+				return true
+			},
+			Hooks: Hooks[string, string]{
+				OnInsert: func(key string, value string) {
+					if key == "key4" {
+						lru.Remove(key)
+					}
+				},
+			},
+		})
+		done := make(chan struct{})
+		go func() {
+			lru.Add("key1", "val1", 0)
+			lru.Add("key2", "val2", 0)
+			lru.Add("key3", "val3", 0) // 驱逐key1
+			lru.Add("key4", "val5", 0)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("callback deadlocked on the key lock")
+		}
+		value, _ := store.get("key3")
+		assert.Equal(t, "val4", value)
+		value, _, _ = lru.Get("key3")
+		assert.Equal(t, "val4", value)
+		_, ok := store.get("key4")
+		assert.False(t, ok)
+		_, ok, _ = lru.Get("key4")
+		assert.False(t, ok)
+	})
+
+	t.Run("saved_not_cached", func(t *testing.T) {
+		// 保存后被准入过滤拒绝时返回ErrSavedNotCached，Store中已有新值
+		store := newMemStore()
+		lru, _ := NewPriorityLRUWithOptions[string, string](1, 1, Options[string, string]{
+			Store:        store,
+			WriteThrough: true,
+			TinyLFU:      true,
+		})
+		assert.NoError(t, lru.Add("key1", "val1", 0))
+		for i := 0; i < 3; i++ {
+			lru.Get("key1")
+		}
+		err := lru.Add("key2", "val2", 0)
+		assert.True(t, errors.Is(err, ErrSavedNotCached))
+		assert.True(t, errors.Is(err, ErrRejected))
+		value, _ := store.get("key2")
+		assert.Equal(t, "val2", value)
+		_, ok, _ := lru.Get("key2")
+		assert.False(t, ok)
+		// Save期间关闭缓存
+		store.onSave = func(key string) {
+			lru.Close()
+		}
+		err = lru.Add("key3", "val3", 0)
+		assert.True(t, errors.Is(err, ErrSavedNotCached))
+		assert.True(t, errors.Is(err, ErrClosed))
+		value, _ = store.get("key3")
+		assert.Equal(t, "val3", value)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		// 同一个key的并发写入串行化，缓存与Store的最新值一致
+		store := newMemStore()
+		lru := newWriteThroughLRU(100, store)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				r := rand.New(rand.NewSource(int64(g)))
+				for i := 0; i < 500; i++ {
+					key := fmt.Sprintf("key%d", r.Intn(4))
+					switch r.Intn(4) {
+					case 0:
+						lru.Remove(key)
+					case 1:
+						lru.GetOrLoad(context.Background(), key, 0)
+					default:
+						lru.Add(key, fmt.Sprintf("%d-%d", g, i), 0)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		for i := 0; i < 4; i++ {
+			key := fmt.Sprintf("key%d", i)
+			value, ok, _ := lru.Get(key)
+			if !ok {
+				continue
+			}
+			stored, ok := store.get(key)
+			assert.True(t, ok)
+			assert.Equal(t, stored, value)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		_, err := NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{WriteThrough: true})
+		assert.Equal(t, ErrStoreRequired, err)
+		_, err = NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{
+			Store:        newMemStore(),
+			WriteBack:    true,
+			WriteThrough: true,
+		})
+		assert.Error(t, err)
+		// 种子哈希下用独立的种子分配key锁
+		store := newMemStore()
+		lru, err := NewPriorityLRUWithOptions[string, string](2, 1, Options[string, string]{
+			Store:        store,
+			WriteThrough: true,
+			SeededHash:   true,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, lru.Add("key1", "val1", 0))
+		assert.NoError(t, lru.Close())
+		assert.Equal(t, ErrClosed, lru.Add("key2", "val2", 0))
+		_, ok := store.get("key2")
+		assert.False(t, ok)
+	})
+}